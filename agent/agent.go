@@ -0,0 +1,309 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Agent runs a conversation with a model that has access to tools.
+type Agent struct {
+	Client *openai.Client
+	Model  openai.ChatModel
+	Tools  tool.Tools
+
+	// History holds the conversation. Defaults to an unbounded history.
+	History *History
+
+	// Transcript, if set, records every message of the conversation.
+	Transcript *Transcript
+
+	// Meter, if set, accounts for the usage of every model call and aborts
+	// runs exceeding its budget.
+	Meter *Meter
+
+	// ResultMode selects how structured results are obtained from the model.
+	ResultMode ResultMode
+
+	// ResultRetries is the number of times the model is asked to fix a
+	// result that doesn't match its schema. Defaults to 2.
+	ResultRetries int
+
+	// MaxToolFailures is the number of consecutive failed tool calls after
+	// which a run is aborted. Failures are otherwise reported to the model.
+	// Defaults to 3, zero means no limit.
+	MaxToolFailures int
+
+	// Limits bound every run. Defaults to DefaultLimits.
+	Limits Limits
+
+	// Selector, if set, picks the tools sent on each request instead of
+	// sending all of them. It should be built over Tools.
+	Selector *ToolSelector
+
+	// RequestOptions apply to every model call, e.g. to set the temperature
+	// with option.WithJSONSet.
+	RequestOptions []option.RequestOption
+}
+
+func New(client *openai.Client, tools tool.Tools) *Agent {
+	return &Agent{
+		Client:          client,
+		Model:           openai.ChatModelGPT4o,
+		Tools:           tools,
+		History:         NewHistory(0),
+		ResultRetries:   2,
+		MaxToolFailures: 3,
+		Limits:          DefaultLimits,
+	}
+}
+
+// Append adds messages to the conversation without running the model, e.g.
+// to set up a system prompt.
+func (a *Agent) Append(msgs ...*Message) error {
+	for _, m := range msgs {
+		if err := a.record(&Entry{Message: m}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run sends input to the model and runs the tool calls it requests until it
+// comes up with an answer.
+func (a *Agent) Run(ctx context.Context, input string) (*Message, error) {
+	reply, _, err := a.run(ctx, input, nil)
+	return reply, err
+}
+
+func (a *Agent) run(ctx context.Context, input string, result *resultRun) (*Message, json.RawMessage, error) {
+	a.Meter.StartTurn(input)
+
+	if err := a.record(&Entry{Message: UserMessage(input)}); err != nil {
+		return nil, nil, err
+	}
+
+	guard := newGuard(a.Limits)
+	ctx, cancel := guard.deadline(ctx)
+	defer cancel()
+	// stopped returns the error of a step that failed because the run was
+	// stopped: the reason it was stopped.
+	stopped := func(err error) error {
+		if ctx.Err() == nil {
+			return err
+		}
+		cause := context.Cause(ctx)
+		if errors.Is(cause, ErrLimitExceeded) {
+			a.Transcript.Record(&Entry{Error: cause.Error()})
+		}
+		return cause
+	}
+
+	failures := 0
+	for {
+		if err := guard.step(); err != nil {
+			a.Transcript.Record(&Entry{Error: err.Error()})
+			return nil, nil, err
+		}
+
+		reply, err := a.complete(ctx, result)
+		if err != nil {
+			return nil, nil, stopped(err)
+		}
+
+		if len(reply.ToolCalls) == 0 {
+			if result == nil {
+				return reply, nil, nil
+			}
+			data, err := result.answer(a, reply)
+			if err != nil || data != nil {
+				return reply, data, err
+			}
+			continue
+		}
+
+		for i, call := range reply.ToolCalls {
+			if result != nil && result.isSubmit(call) {
+				data, err := result.submit(a, call)
+				if err != nil {
+					return nil, nil, a.abandon(reply.ToolCalls[i+1:], err)
+				}
+				if data == nil {
+					continue
+				}
+				// The result is final: the calls that follow it don't run.
+				if err := a.abandon(reply.ToolCalls[i+1:], errResultSubmitted); errors.Is(err, ErrTranscript) {
+					return nil, nil, err
+				}
+				return reply, data, nil
+			}
+			nudge, err := guard.repeat(call)
+			if err != nil {
+				return nil, nil, a.abandon(reply.ToolCalls[i:], err)
+			}
+			if nudge != "" {
+				fmt.Fprintf(os.Stderr, "=> skipping repeated call: %s(%s)\n", call.Name, call.Arguments)
+				if err := a.record(&Entry{Message: ToolMessage(call.ID, nudge), Tool: call.Name}); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+
+			err = a.call(ctx, call)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			if errors.Is(err, ErrTranscript) {
+				return nil, nil, err
+			}
+			if ctx.Err() != nil {
+				err = stopped(err)
+				return nil, nil, a.abandon(reply.ToolCalls[i+1:], err)
+			}
+			failures++
+			if a.MaxToolFailures > 0 && failures >= a.MaxToolFailures {
+				err = fmt.Errorf("%w (%d): %w", ErrTooManyFailures, failures, err)
+				return nil, nil, a.abandon(reply.ToolCalls[i+1:], err)
+			}
+		}
+	}
+}
+
+func (a *Agent) complete(ctx context.Context, result *resultRun) (*Message, error) {
+	meters := a.meters(ctx)
+	for _, m := range meters {
+		if err := m.Check(); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.History.Compact(ctx); err != nil {
+		return nil, err
+	}
+
+	params := openai.ChatCompletionNewParams{
+		Messages: openai.F(a.History.Params()),
+		Tools:    openai.F(a.selectTools().Functions()),
+		Seed:     openai.Int(0),
+		Model:    openai.F(a.Model),
+	}
+	if result != nil {
+		result.apply(&params)
+	}
+
+	start := time.Now()
+	completion, err := a.Client.Chat.Completions.New(ctx, params, a.RequestOptions...)
+	if err != nil {
+		return nil, err
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in the completion %s", completion.ID)
+	}
+
+	reply := AssistantMessage(completion.Choices[0].Message)
+	var meterErr error
+	for _, m := range meters {
+		if err := m.Record(completion, a.History.Messages()); err != nil && meterErr == nil {
+			meterErr = err
+		}
+	}
+	if err := a.record(&Entry{
+		Message:  reply,
+		Duration: time.Since(start),
+		Model:    NewModelInfo(completion),
+	}); err != nil {
+		return nil, err
+	}
+	if meterErr != nil {
+		return nil, a.abandon(reply.ToolCalls, meterErr)
+	}
+	return reply, nil
+}
+
+// call runs a tool call and records its outcome. Failures are recorded for
+// the model to see, and returned.
+func (a *Agent) call(ctx context.Context, call ToolCall) error {
+	fmt.Fprintf(os.Stderr, "=> invoking tool: %s(%s)\n", call.Name, call.Arguments)
+
+	start := time.Now()
+	ctx = context.WithValue(ctx, callerKey{}, a)
+	ctx = context.WithValue(ctx, metersKey{}, a.meters(ctx))
+	response, err := a.tools().Dispatch(ctx, call.Name, call.Arguments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "=> %s failed: %v\n", call.Name, err)
+		failure := NewToolFailure(err)
+		if err := a.record(&Entry{
+			Message:  ToolMessage(call.ID, failure.Content()),
+			Duration: time.Since(start),
+			Tool:     call.Name,
+			Error:    failure.Message,
+		}); err != nil {
+			return err
+		}
+
+		// Denials are decisions, not failures.
+		var denied *tool.DeniedError
+		if errors.As(err, &denied) {
+			return nil
+		}
+		return err
+	}
+
+	return a.record(&Entry{
+		Message:  ToolMessage(call.ID, response),
+		Duration: time.Since(start),
+		Tool:     call.Name,
+	})
+}
+
+// selectTools returns the tools sent to the model with the next request.
+func (a *Agent) selectTools() tool.Tools {
+	if a.Selector == nil {
+		return a.Tools
+	}
+	return a.Selector.Select(a.History.Messages())
+}
+
+// tools returns the tools the model may call.
+func (a *Agent) tools() tool.Tools {
+	if a.Selector == nil {
+		return a.Tools
+	}
+	return a.Selector.Tools()
+}
+
+// abandon answers tool calls that won't run because of err. Tool calls must
+// be answered for the conversation to remain valid.
+//
+// It returns err, or the first error recording the answers.
+func (a *Agent) abandon(calls []ToolCall, err error) error {
+	var recordErr error
+	for _, call := range calls {
+		if rerr := a.record(&Entry{Message: ToolMessage(call.ID, "not executed: "+err.Error())}); rerr != nil && recordErr == nil {
+			recordErr = rerr
+		}
+	}
+	if recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+// ErrTranscript is returned when the conversation can't be recorded to the
+// transcript.
+var ErrTranscript = errors.New("unable to record transcript")
+
+func (a *Agent) record(e *Entry) error {
+	a.History.Append(e.Message)
+	if err := a.Transcript.Record(e); err != nil {
+		return fmt.Errorf("%w: %w", ErrTranscript, err)
+	}
+	return nil
+}
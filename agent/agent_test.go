@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// fakeModel is an OpenAI compatible server answering with scripted
+// completions, in order.
+type fakeModel struct {
+	mu       sync.Mutex
+	replies  []map[string]any
+	requests []map[string]any
+}
+
+// newFakeModel returns a client of a server answering with replies.
+func newFakeModel(t *testing.T, replies ...map[string]any) (*openai.Client, *fakeModel) {
+	t.Helper()
+	m := &fakeModel{replies: replies}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		m.mu.Lock()
+		m.requests = append(m.requests, req)
+		if len(m.replies) == 0 {
+			m.mu.Unlock()
+			http.Error(w, `{"error": {"message": "no more replies"}}`, http.StatusBadRequest)
+			return
+		}
+		reply := m.replies[0]
+		m.replies = m.replies[1:]
+		m.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reply)
+	}))
+	t.Cleanup(srv.Close)
+	client := openai.NewClient(
+		option.WithBaseURL(srv.URL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+	)
+	return client, m
+}
+
+// completion returns a completion with an answer or, given calls as
+// name/arguments pairs, tool calls.
+func completion(content string, calls ...string) map[string]any {
+	message := map[string]any{"role": "assistant", "content": content}
+	finish := "stop"
+	if len(calls) > 0 {
+		toolCalls := []any{}
+		for i := 0; i < len(calls); i += 2 {
+			toolCalls = append(toolCalls, map[string]any{
+				"id":       fmt.Sprintf("call_%d", i/2),
+				"type":     "function",
+				"function": map[string]any{"name": calls[i], "arguments": calls[i+1]},
+			})
+		}
+		message["tool_calls"] = toolCalls
+		finish = "tool_calls"
+	}
+	return map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"model":   "gpt-4o",
+		"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": finish}},
+		"usage":   map[string]any{"prompt_tokens": 100, "completion_tokens": 10, "total_tokens": 110},
+	}
+}
+
+type echoArgs struct {
+	Text string `json:"text"`
+}
+
+func echo() tool.Tool {
+	return tool.Func("echo", "Echo text.", func(_ context.Context, args echoArgs) (string, error) {
+		return args.Text, nil
+	})
+}
+
+func fail() tool.Tool {
+	return tool.Func("fail", "Always fail.", func(context.Context, echoArgs) (string, error) {
+		return "", errors.New("boom")
+	})
+}
+
+func TestRunToolCalls(t *testing.T) {
+	client, model := newFakeModel(t,
+		completion("", "echo", `{"text": "hello"}`),
+		completion("done"),
+	)
+	a := New(client, tool.Tools{echo()})
+	reply, err := a.Run(context.Background(), "say hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "done" {
+		t.Errorf("reply = %q", reply.Content)
+	}
+	msgs := a.History.Messages()
+	if len(msgs) != 4 || msgs[2].Role != RoleTool || msgs[2].Content != `"hello"` {
+		t.Errorf("unexpected history %+v", msgs)
+	}
+	if len(model.requests) != 2 {
+		t.Errorf("got %d model requests, want 2", len(model.requests))
+	}
+}
+
+// failingWriter fails the write after the first n, and only that one.
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.n--
+	if w.n == -1 {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestRunTranscriptFailure(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		tool tool.Tool
+	}{
+		{"success", echo()},
+		{"failure", fail()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newFakeModel(t,
+				completion("", tt.tool.Name(), `{"text": "hello"}`),
+				completion("done"),
+			)
+			a := New(client, tool.Tools{tt.tool})
+			// The user message and the tool call are recorded, not the result.
+			a.Transcript = NewTranscript(&failingWriter{n: 2})
+			_, err := a.Run(context.Background(), "go")
+			if !errors.Is(err, ErrTranscript) {
+				t.Errorf("Run() = %v, want %v", err, ErrTranscript)
+			}
+		})
+	}
+}
+
+func TestSubAgentUsage(t *testing.T) {
+	client, _ := newFakeModel(t,
+		completion("", "agent_helper", `{"instructions": "echo hello"}`),
+		completion("", "echo", `{"text": "hello"}`),
+		completion("hello"),
+	)
+
+	sub := New(client, tool.Tools{echo()})
+	sub.Meter = NewMeter(nil, Budget{})
+	parent := New(client, tool.Tools{sub.AsTool("helper", "Delegate a task.")})
+	parent.Meter = NewMeter(nil, Budget{MaxTokens: 300})
+
+	_, err := parent.Run(context.Background(), "say hello")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Run() = %v, want %v", err, ErrBudgetExceeded)
+	}
+	if got := parent.Meter.Total(); got.Calls != 3 || got.Tokens() != 330 {
+		t.Errorf("parent usage = %s, want the 3 calls", got)
+	}
+	if got := sub.Meter.Total(); got.Calls != 2 {
+		t.Errorf("sub-agent usage = %s, want its 2 calls", got)
+	}
+}
+
+func TestRunNoChoices(t *testing.T) {
+	empty := completion("done")
+	empty["choices"] = []any{}
+	client, _ := newFakeModel(t, empty)
+	a := New(client, tool.Tools{echo()})
+	_, err := a.Run(context.Background(), "go")
+	if err == nil {
+		t.Fatal("Run() succeeded without choices")
+	}
+}
+
+func TestRunAbandonTranscriptFailure(t *testing.T) {
+	client, _ := newFakeModel(t,
+		completion("", "fail", `{"text": "hello"}`, "echo", `{"text": "hello"}`),
+	)
+	a := New(client, tool.Tools{echo(), fail()})
+	a.MaxToolFailures = 1
+	// The user message, the tool calls and the failure are recorded, not
+	// the abandoned call.
+	a.Transcript = NewTranscript(&failingWriter{n: 3})
+	_, err := a.Run(context.Background(), "go")
+	if !errors.Is(err, ErrTranscript) {
+		t.Errorf("Run() = %v, want %v", err, ErrTranscript)
+	}
+}
+
+func TestSubAgentWithoutMeter(t *testing.T) {
+	client, _ := newFakeModel(t,
+		completion("", "agent_helper", `{"instructions": "echo hello"}`),
+		completion("", "echo", `{"text": "hello"}`),
+		completion("hello"),
+		completion("done"),
+	)
+
+	sub := New(client, tool.Tools{echo()})
+	parent := New(client, tool.Tools{sub.AsTool("helper", "Delegate a task.")})
+	parent.Meter = NewMeter(nil, Budget{})
+
+	if _, err := parent.Run(context.Background(), "say hello"); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Meter != nil {
+		t.Error("the sub-agent was given a meter")
+	}
+	turns := parent.Meter.Turns()
+	if len(turns) != 1 || turns[0].Label != "say hello" {
+		t.Fatalf("parent turns = %+v, want the single turn of the parent", turns)
+	}
+	if got := len(turns[0].Steps); got != 4 {
+		t.Errorf("parent turn has %d steps, want the 4 calls", got)
+	}
+}
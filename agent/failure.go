@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/aluzzardi/langdag/retry"
+	"github.com/aluzzardi/langdag/tool"
+)
+
+// Kinds of tool failures.
+const (
+	FailureInvalidArguments = "invalid_arguments"
+	FailureUnknownTool      = "unknown_tool"
+	FailureDenied           = "denied"
+	FailureTimeout          = "timeout"
+	FailureExec             = "exec"
+	FailureQuery            = "query"
+	FailureError            = "error"
+)
+
+// ErrTooManyFailures is returned when tool calls keep failing.
+var ErrTooManyFailures = errors.New("too many consecutive tool failures")
+
+// ToolFailure is sent back to the model in place of the result of a failed
+// tool call, so that it can adapt.
+type ToolFailure struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+
+	// Problems lists what's wrong with the arguments.
+	Problems []string `json:"problems,omitempty"`
+
+	// Command and ExitCode describe the command that failed.
+	Command  string `json:"command,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+
+	// Retryable is set for transient failures, worth trying again.
+	Retryable bool `json:"retryable,omitempty"`
+}
+
+func NewToolFailure(err error) *ToolFailure {
+	f := &ToolFailure{
+		Kind:      FailureError,
+		Message:   err.Error(),
+		Retryable: retry.IsTransient(err),
+	}
+
+	var (
+		args    *tool.ArgumentsError
+		denied  *tool.DeniedError
+		timeout *retry.TimeoutError
+		exec    *tool.ExecError
+		query   *tool.QueryError
+	)
+	switch {
+	case errors.As(err, &args):
+		f.Kind = FailureInvalidArguments
+		f.Problems = args.Problems
+	case errors.Is(err, tool.ErrNotFound):
+		f.Kind = FailureUnknownTool
+	case errors.As(err, &denied):
+		f.Kind = FailureDenied
+	case errors.As(err, &timeout):
+		f.Kind = FailureTimeout
+	case errors.As(err, &exec):
+		f.Kind = FailureExec
+		f.Message = exec.Compact()
+		f.Command = exec.Command
+		f.ExitCode = &exec.ExitCode
+	case errors.As(err, &query):
+		f.Kind = FailureQuery
+	}
+	return f
+}
+
+// Content renders the failure as the content of a tool message.
+func (f *ToolFailure) Content() string {
+	data, err := json.Marshal(map[string]any{"error": f})
+	if err != nil {
+		return f.Message
+	}
+	return string(data)
+}
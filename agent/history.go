@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go"
+)
+
+// History holds the messages of a conversation and keeps them within a token
+// budget.
+//
+// System messages at the beginning of the history are always kept. Everything
+// else is grouped in turns (a user message followed by the assistant and tool
+// messages it caused) so that strategies never separate a tool call from its
+// result.
+type History struct {
+	// MaxTokens is the estimated token budget. Zero disables compaction.
+	MaxTokens int
+
+	// Strategies are applied in order by Compact until the history fits
+	// within MaxTokens.
+	Strategies []Strategy
+
+	messages []*Message
+}
+
+func NewHistory(maxTokens int, strategies ...Strategy) *History {
+	return &History{
+		MaxTokens:  maxTokens,
+		Strategies: strategies,
+	}
+}
+
+func (h *History) Append(msgs ...*Message) {
+	h.messages = append(h.messages, msgs...)
+}
+
+func (h *History) Messages() []*Message {
+	return h.messages
+}
+
+func (h *History) Params() []openai.ChatCompletionMessageParamUnion {
+	params := make([]openai.ChatCompletionMessageParamUnion, 0, len(h.messages))
+	for _, m := range h.messages {
+		params = append(params, m.Param())
+	}
+	return params
+}
+
+func (h *History) Tokens() int {
+	return countTokens(h.messages)
+}
+
+// Compact runs the configured strategies until the history fits the budget.
+//
+// It is not an error for the history to remain over budget once all the
+// strategies have run: the last turn is never touched.
+func (h *History) Compact(ctx context.Context) error {
+	if h.MaxTokens <= 0 {
+		return nil
+	}
+	for _, s := range h.Strategies {
+		if h.Tokens() <= h.MaxTokens {
+			return nil
+		}
+		system, turns := splitTurns(h.messages)
+		turns, err := s.Compact(ctx, turns, h.MaxTokens-countTokens(system))
+		if err != nil {
+			return fmt.Errorf("compact history: %w", err)
+		}
+		h.messages = joinTurns(system, turns)
+	}
+	return nil
+}
+
+// Turn is a user message along with the assistant and tool messages that
+// followed it.
+type Turn []*Message
+
+func (t Turn) Tokens() int {
+	return countTokens(t)
+}
+
+// Strategy reduces the size of a conversation.
+//
+// Compact receives every turn but the pinned system prompt and must return
+// turns that fit within budget if it can. Strategies must preserve the
+// pairing between tool calls and their results, and must keep the last turn.
+type Strategy interface {
+	Compact(ctx context.Context, turns []Turn, budget int) ([]Turn, error)
+}
+
+// DropOldestTurns removes whole turns, starting from the oldest one.
+type DropOldestTurns struct{}
+
+func (DropOldestTurns) Compact(_ context.Context, turns []Turn, budget int) ([]Turn, error) {
+	for len(turns) > 1 && countTurnTokens(turns) > budget {
+		turns = turns[1:]
+	}
+	return turns, nil
+}
+
+// TruncateToolResults shortens the content of tool results, starting from the
+// oldest one.
+type TruncateToolResults struct {
+	// MaxChars is the length tool results are truncated to.
+	MaxChars int
+
+	// KeepRecent is the number of most recent tool results left untouched.
+	KeepRecent int
+}
+
+func (s TruncateToolResults) Compact(_ context.Context, turns []Turn, budget int) ([]Turn, error) {
+	type ref struct{ turn, msg int }
+	results := []ref{}
+	for i, turn := range turns {
+		for j, m := range turn {
+			if m.Role == RoleTool && len(m.Content) > s.MaxChars {
+				results = append(results, ref{i, j})
+			}
+		}
+	}
+	if len(results) <= s.KeepRecent {
+		return turns, nil
+	}
+	results = results[:len(results)-s.KeepRecent]
+
+	for _, r := range results {
+		if countTurnTokens(turns) <= budget {
+			break
+		}
+		turn := make(Turn, len(turns[r.turn]))
+		copy(turn, turns[r.turn])
+		m := *turn[r.msg]
+		m.Content = truncate(m.Content, s.MaxChars)
+		turn[r.msg] = &m
+		turns[r.turn] = turn
+	}
+	return turns, nil
+}
+
+// Summarizer condenses messages into a short text.
+type Summarizer func(ctx context.Context, msgs []*Message) (string, error)
+
+// SummarizeTurns replaces the oldest turns with a summary written by the
+// model.
+type SummarizeTurns struct {
+	// Summarizer writes the summary. Compaction fails without one.
+	Summarizer Summarizer
+
+	// KeepTurns is the number of most recent turns left untouched. It is
+	// always at least 1.
+	KeepTurns int
+}
+
+func (s SummarizeTurns) Compact(ctx context.Context, turns []Turn, budget int) ([]Turn, error) {
+	keep := max(s.KeepTurns, 1)
+	if len(turns) <= keep {
+		return turns, nil
+	}
+	if s.Summarizer == nil {
+		return nil, fmt.Errorf("summarize: no summarizer configured")
+	}
+
+	old := []*Message{}
+	for _, turn := range turns[:len(turns)-keep] {
+		old = append(old, turn...)
+	}
+	summary, err := s.Summarizer(ctx, old)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Turn{{
+		UserMessage("Summary of the conversation so far:\n" + summary),
+	}}, turns[len(turns)-keep:]...), nil
+}
+
+const summarizePrompt = `Summarize the following conversation between a user and an assistant using tools.
+Keep facts, decisions, identifiers (repositories, issue and pull request numbers, file names) and pending tasks. Drop raw tool output.`
+
+// ModelSummarizer returns a Summarizer that asks the model to summarize.
+func ModelSummarizer(client *openai.Client, model openai.ChatModel) Summarizer {
+	return func(ctx context.Context, msgs []*Message) (string, error) {
+		sb := new(strings.Builder)
+		for _, m := range msgs {
+			switch {
+			case m.Role == RoleTool:
+				fmt.Fprintf(sb, "[tool result]: %s\n", truncate(m.Content, 2000))
+			case len(m.ToolCalls) > 0:
+				for _, call := range m.ToolCalls {
+					fmt.Fprintf(sb, "[tool call]: %s(%s)\n", call.Name, call.Arguments)
+				}
+			default:
+				fmt.Fprintf(sb, "[%s]: %s\n", m.Role, m.Content)
+			}
+		}
+
+		completion, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage(summarizePrompt),
+				openai.UserMessage(sb.String()),
+			}),
+			Model: openai.F(model),
+		})
+		if err != nil {
+			return "", fmt.Errorf("summarize: %w", err)
+		}
+		if len(completion.Choices) == 0 {
+			return "", fmt.Errorf("summarize: no choices in the completion")
+		}
+		return completion.Choices[0].Message.Content, nil
+	}
+}
+
+func splitTurns(msgs []*Message) ([]*Message, []Turn) {
+	i := 0
+	for i < len(msgs) && msgs[i].Role == RoleSystem {
+		i++
+	}
+	system := msgs[:i]
+
+	turns := []Turn{}
+	for _, m := range msgs[i:] {
+		if m.Role == RoleUser || len(turns) == 0 {
+			turns = append(turns, Turn{})
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], m)
+	}
+	return system, turns
+}
+
+func joinTurns(system []*Message, turns []Turn) []*Message {
+	msgs := make([]*Message, 0, len(system)+len(turns))
+	msgs = append(msgs, system...)
+	for _, turn := range turns {
+		msgs = append(msgs, turn...)
+	}
+	return msgs
+}
+
+func countTokens(msgs []*Message) int {
+	n := 0
+	for _, m := range msgs {
+		n += m.Tokens()
+	}
+	return n
+}
+
+func countTurnTokens(turns []Turn) int {
+	n := 0
+	for _, turn := range turns {
+		n += turn.Tokens()
+	}
+	return n
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	total := len(s)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + fmt.Sprintf("\n... [truncated %d characters]", total-n)
+}
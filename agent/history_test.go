@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// conversation returns a system prompt followed by n turns, each a user
+// message, a tool call, its result of resultSize characters and an answer.
+func conversation(n, resultSize int) []*Message {
+	msgs := []*Message{SystemMessage("You are helpful.")}
+	for i := range n {
+		id := string(rune('a' + i))
+		msgs = append(msgs,
+			UserMessage("question "+id),
+			&Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: id, Name: "lookup", Arguments: "{}"}}},
+			ToolMessage(id, strings.Repeat("x", resultSize)),
+			&Message{Role: RoleAssistant, Content: "answer " + id},
+		)
+	}
+	return msgs
+}
+
+// checkPairing fails if a tool result doesn't follow its call.
+func checkPairing(t *testing.T, msgs []*Message) {
+	t.Helper()
+	calls := map[string]bool{}
+	for _, m := range msgs {
+		for _, call := range m.ToolCalls {
+			calls[call.ID] = true
+		}
+		if m.Role == RoleTool && !calls[m.ToolCallID] {
+			t.Errorf("tool result %s without its call", m.ToolCallID)
+		}
+	}
+}
+
+func TestSplitTurns(t *testing.T) {
+	system, turns := splitTurns(conversation(3, 10))
+	if len(system) != 1 || system[0].Role != RoleSystem {
+		t.Fatalf("system = %v", system)
+	}
+	if len(turns) != 3 {
+		t.Fatalf("got %d turns, want 3", len(turns))
+	}
+	for _, turn := range turns {
+		if len(turn) != 4 || turn[0].Role != RoleUser {
+			t.Errorf("unexpected turn %v", turn)
+		}
+	}
+}
+
+func TestDropOldestTurns(t *testing.T) {
+	h := NewHistory(200, DropOldestTurns{})
+	h.Append(conversation(5, 200)...)
+	if err := h.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := h.Messages()
+	if msgs[0].Role != RoleSystem {
+		t.Errorf("system prompt was dropped")
+	}
+	if h.Tokens() > 200 {
+		t.Errorf("history uses %d tokens, over budget", h.Tokens())
+	}
+	if last := msgs[len(msgs)-1]; last.Content != "answer e" {
+		t.Errorf("last turn was dropped: %q", last.Content)
+	}
+	checkPairing(t, msgs)
+}
+
+func TestDropOldestTurnsKeepsLastTurn(t *testing.T) {
+	h := NewHistory(10, DropOldestTurns{})
+	h.Append(conversation(2, 1000)...)
+	if err := h.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(h.Messages()); got != 5 {
+		t.Errorf("got %d messages, want the system prompt and the last turn", got)
+	}
+}
+
+func TestTruncateToolResults(t *testing.T) {
+	h := NewHistory(500, TruncateToolResults{MaxChars: 100, KeepRecent: 1})
+	h.Append(conversation(3, 1000)...)
+	if err := h.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []*Message{}
+	for _, m := range h.Messages() {
+		if m.Role == RoleTool {
+			results = append(results, m)
+		}
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d tool results, want 3", len(results))
+	}
+	for _, m := range results[:2] {
+		if !strings.Contains(m.Content, "[truncated 900 characters]") {
+			t.Errorf("result %s wasn't truncated: %d characters", m.ToolCallID, len(m.Content))
+		}
+	}
+	if len(results[2].Content) != 1000 {
+		t.Errorf("most recent result was truncated")
+	}
+	checkPairing(t, h.Messages())
+}
+
+func TestTruncateToolResultsWithinBudget(t *testing.T) {
+	msgs := conversation(3, 1000)
+	h := NewHistory(10000, TruncateToolResults{MaxChars: 100})
+	h.Append(msgs...)
+	if err := h.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range h.Messages() {
+		if m != msgs[i] {
+			t.Errorf("message %d changed although the history fits", i)
+		}
+	}
+}
+
+func TestSummarizeTurns(t *testing.T) {
+	var summarized []*Message
+	s := SummarizeTurns{
+		Summarizer: func(_ context.Context, msgs []*Message) (string, error) {
+			summarized = msgs
+			return "the user asked questions", nil
+		},
+		KeepTurns: 1,
+	}
+	h := NewHistory(100, s)
+	h.Append(conversation(3, 100)...)
+	if err := h.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summarized) != 8 {
+		t.Errorf("summarized %d messages, want the 2 oldest turns", len(summarized))
+	}
+	msgs := h.Messages()
+	if len(msgs) != 6 {
+		t.Fatalf("got %d messages, want system, summary and the last turn", len(msgs))
+	}
+	if msgs[1].Role != RoleUser || !strings.Contains(msgs[1].Content, "the user asked questions") {
+		t.Errorf("unexpected summary %+v", msgs[1])
+	}
+	checkPairing(t, msgs)
+}
+
+func TestCompactError(t *testing.T) {
+	failure := errors.New("no model")
+	h := NewHistory(10, SummarizeTurns{
+		Summarizer: func(context.Context, []*Message) (string, error) { return "", failure },
+	})
+	h.Append(conversation(3, 100)...)
+	if err := h.Compact(context.Background()); !errors.Is(err, failure) {
+		t.Errorf("Compact() = %v, want %v", err, failure)
+	}
+}
+
+func TestSummarizeTurnsWithoutSummarizer(t *testing.T) {
+	h := NewHistory(10, SummarizeTurns{})
+	h.Append(conversation(3, 100)...)
+	if err := h.Compact(context.Background()); err == nil {
+		t.Error("Compact() succeeded without a summarizer")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("truncate() = %q", got)
+	}
+	// Never cut a rune in half.
+	got := truncate("ééé", 3)
+	if !strings.HasPrefix(got, "é\n") {
+		t.Errorf("truncate() = %q", got)
+	}
+}
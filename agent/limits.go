@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Limits bound a single run. Zero values are unlimited.
+type Limits struct {
+	// MaxSteps is the number of model calls a run may make.
+	MaxSteps int
+
+	// MaxDuration is the wall-clock time a run may take. Model and tool
+	// calls in flight when it's reached are canceled.
+	MaxDuration time.Duration
+
+	// MaxRepeats is the number of times a tool may be called with the same
+	// arguments. The next identical call is skipped and the model nudged to
+	// change course; the run is stopped if it carries on.
+	MaxRepeats int
+}
+
+var DefaultLimits = Limits{
+	MaxSteps:   50,
+	MaxRepeats: 3,
+}
+
+// ErrLimitExceeded is returned, wrapped in a *LimitError, by runs stopped by
+// their Limits.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError explains why a run was stopped.
+type LimitError struct {
+	// Limit is the limit that was hit: "steps", "duration" or "repeats".
+	Limit  string
+	Reason string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("run stopped: %s", e.Reason)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// guard enforces the limits of a run.
+type guard struct {
+	limits Limits
+	start  time.Time
+	steps  int
+	calls  map[string]int
+	nudged map[string]bool
+}
+
+func newGuard(limits Limits) *guard {
+	return &guard{
+		limits: limits,
+		start:  time.Now(),
+		calls:  map[string]int{},
+		nudged: map[string]bool{},
+	}
+}
+
+// step accounts for a model call, or returns why it may not happen.
+func (g *guard) step() error {
+	if g.limits.MaxSteps > 0 && g.steps >= g.limits.MaxSteps {
+		return &LimitError{Limit: "steps", Reason: fmt.Sprintf("reached the maximum of %d steps without an answer", g.limits.MaxSteps)}
+	}
+	if elapsed := time.Since(g.start); g.limits.MaxDuration > 0 && elapsed > g.limits.MaxDuration {
+		return &LimitError{Limit: "duration", Reason: fmt.Sprintf("ran for %s, more than the maximum of %s", elapsed.Round(time.Second), g.limits.MaxDuration)}
+	}
+	g.steps++
+	return nil
+}
+
+// deadline returns a context canceled once the run has taken its maximum
+// duration, with a *LimitError as cause.
+func (g *guard) deadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.limits.MaxDuration <= 0 {
+		return context.WithCancel(ctx)
+	}
+	cause := &LimitError{Limit: "duration", Reason: fmt.Sprintf("ran for more than the maximum of %s", g.limits.MaxDuration)}
+	return context.WithDeadlineCause(ctx, g.start.Add(g.limits.MaxDuration), cause)
+}
+
+// repeat accounts for a tool call. It returns a nudge for the model if the
+// call should be skipped, or an error if the run should stop.
+func (g *guard) repeat(call ToolCall) (string, error) {
+	if g.limits.MaxRepeats <= 0 {
+		return "", nil
+	}
+
+	key := call.Name + "(" + normalizeArguments(call.Arguments) + ")"
+	g.calls[key]++
+	n := g.calls[key]
+	if n <= g.limits.MaxRepeats {
+		return "", nil
+	}
+	if g.nudged[key] {
+		return "", &LimitError{Limit: "repeats", Reason: fmt.Sprintf("%s was called %d times with the same arguments: %s", call.Name, n, call.Arguments)}
+	}
+	g.nudged[key] = true
+	return fmt.Sprintf("Not executed: %s was already called %d times with these exact arguments and the result won't change. Use the results you already have, try something different, or answer with what you know.", call.Name, n-1), nil
+}
+
+// normalizeArguments makes identical arguments compare equal regardless of
+// formatting and key order.
+func normalizeArguments(arguments string) string {
+	var v any
+	if err := json.Unmarshal([]byte(arguments), &v); err != nil {
+		return arguments
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return arguments
+	}
+	return string(data)
+}
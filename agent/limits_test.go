@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestMaxDurationTool(t *testing.T) {
+	client, _ := newFakeModel(t,
+		completion("", "wait", `{"text": "forever"}`, "echo", `{"text": "hello"}`),
+		completion("done"),
+	)
+	wait := tool.Func("wait", "Wait.", func(ctx context.Context, _ echoArgs) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	a := New(client, tool.Tools{wait, echo()})
+	a.Limits.MaxDuration = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := a.Run(context.Background(), "wait")
+	var limit *LimitError
+	if !errors.As(err, &limit) || limit.Limit != "duration" {
+		t.Fatalf("Run() = %v, want a duration limit error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run took %s", elapsed)
+	}
+	// Both calls are answered to keep the conversation valid.
+	msgs := a.History.Messages()
+	if len(msgs) != 4 || msgs[2].Role != RoleTool || msgs[3].Role != RoleTool {
+		t.Errorf("unexpected history %+v", msgs)
+	}
+}
+
+func TestMaxDurationModel(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) })
+	client := openai.NewClient(
+		option.WithBaseURL(srv.URL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+	)
+	a := New(client, tool.Tools{})
+	a.Limits.MaxDuration = 50 * time.Millisecond
+
+	_, err := a.Run(context.Background(), "hello")
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Run() = %v, want %v", err, ErrLimitExceeded)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client, _ := newFakeModel(t, completion("", "cancel", `{"text": ""}`))
+	a := New(client, tool.Tools{tool.Func("cancel", "Cancel the run.", func(ctx context.Context, _ echoArgs) (string, error) {
+		cancel()
+		return "", ctx.Err()
+	})})
+	a.Limits.MaxDuration = time.Minute
+
+	if _, err := a.Run(ctx, "go"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() = %v, want %v", err, context.Canceled)
+	}
+}
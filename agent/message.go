@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"github.com/openai/openai-go"
+)
+
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// Message is a provider-agnostic representation of a chat message.
+//
+// It keeps just enough information to rebuild the OpenAI message params while
+// being easy to inspect, rewrite and serialize.
+type Message struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content,omitempty"`
+
+	// ToolCalls are the tool invocations requested by an assistant message.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// ToolCallID is the ID of the tool call a tool message is responding to.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+func SystemMessage(content string) *Message {
+	return &Message{Role: RoleSystem, Content: content}
+}
+
+func UserMessage(content string) *Message {
+	return &Message{Role: RoleUser, Content: content}
+}
+
+func ToolMessage(toolCallID, content string) *Message {
+	return &Message{Role: RoleTool, ToolCallID: toolCallID, Content: content}
+}
+
+// AssistantMessage converts a completion message returned by the model.
+func AssistantMessage(msg openai.ChatCompletionMessage) *Message {
+	m := &Message{
+		Role:    RoleAssistant,
+		Content: msg.Content,
+	}
+	for _, call := range msg.ToolCalls {
+		m.ToolCalls = append(m.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return m
+}
+
+func (m *Message) Param() openai.ChatCompletionMessageParamUnion {
+	switch m.Role {
+	case RoleSystem:
+		return openai.SystemMessage(m.Content)
+	case RoleTool:
+		return openai.ToolMessage(m.ToolCallID, m.Content)
+	case RoleAssistant:
+		msg := openai.ChatCompletionAssistantMessageParam{
+			Role: openai.F(openai.ChatCompletionAssistantMessageParamRoleAssistant),
+		}
+		if m.Content != "" {
+			msg.Content = openai.F([]openai.ChatCompletionAssistantMessageParamContentUnion{
+				openai.TextPart(m.Content),
+			})
+		}
+		if len(m.ToolCalls) > 0 {
+			calls := make([]openai.ChatCompletionMessageToolCallParam, 0, len(m.ToolCalls))
+			for _, call := range m.ToolCalls {
+				calls = append(calls, openai.ChatCompletionMessageToolCallParam{
+					ID:   openai.F(call.ID),
+					Type: openai.F(openai.ChatCompletionMessageToolCallTypeFunction),
+					Function: openai.F(openai.ChatCompletionMessageToolCallFunctionParam{
+						Name:      openai.F(call.Name),
+						Arguments: openai.F(call.Arguments),
+					}),
+				})
+			}
+			msg.ToolCalls = openai.F(calls)
+		}
+		return msg
+	default:
+		return openai.UserMessage(m.Content)
+	}
+}
+
+// Tokens returns an estimate of the number of tokens used by the message.
+func (m *Message) Tokens() int {
+	// Every message carries a few tokens of framing (role, separators).
+	n := 4 + EstimateTokens(m.Content)
+	for _, call := range m.ToolCalls {
+		n += 4 + EstimateTokens(call.Name) + EstimateTokens(call.Arguments)
+	}
+	return n
+}
+
+// EstimateTokens approximates the token count of s.
+//
+// It uses the common rule of thumb of ~4 characters per token, which is close
+// enough for budgeting without pulling in a tokenizer.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
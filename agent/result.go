@@ -0,0 +1,245 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/openai/openai-go"
+)
+
+// ResultSchema declares the structure of the final answer of a run.
+type ResultSchema struct {
+	// Name identifies the result for the model, e.g. "secret_scan_report".
+	Name        string
+	Description string
+	Schema      *jsonschema.Schema
+}
+
+// ResultMode selects how a structured result is obtained from the model.
+type ResultMode int
+
+const (
+	// ResultModeAuto uses the response format when the model supports
+	// structured outputs, and the submit tool otherwise.
+	ResultModeAuto ResultMode = iota
+
+	// ResultModeResponseFormat constrains the final answer with the
+	// `json_schema` response format.
+	ResultModeResponseFormat
+
+	// ResultModeSubmitTool exposes a synthetic tool the model calls with the
+	// result as arguments.
+	ResultModeSubmitTool
+)
+
+// SubmitResultTool is the name of the synthetic tool used by
+// ResultModeSubmitTool.
+const SubmitResultTool = "submit_result"
+
+var ErrInvalidResult = errors.New("invalid result")
+
+// errResultSubmitted abandons the tool calls following an accepted result.
+var errResultSubmitted = errors.New("result already submitted")
+
+// RunResult runs the agent like Run, but expects a final answer matching
+// schema. The answer is validated and the model asked to fix it on mismatch.
+func (a *Agent) RunResult(ctx context.Context, input string, schema *ResultSchema) (json.RawMessage, error) {
+	result := &resultRun{
+		schema:  schema,
+		mode:    a.ResultMode,
+		retries: a.ResultRetries,
+	}
+	if result.mode == ResultModeAuto {
+		result.mode = ResultModeSubmitTool
+		if supportsStructuredOutputs(a.Model) {
+			result.mode = ResultModeResponseFormat
+		}
+	}
+
+	_, data, err := a.run(ctx, input, result)
+	return data, err
+}
+
+// RunAs runs the agent and decodes its final answer into a T. The result
+// schema is derived from T (see jsonschema.For) and named after it.
+func RunAs[T any](ctx context.Context, a *Agent, input string) (T, error) {
+	var v T
+
+	// Type arguments are left out of the names of generic types.
+	name, _, _ := strings.Cut(reflect.TypeFor[T]().Name(), "[")
+
+	data, err := a.RunResult(ctx, input, &ResultSchema{
+		Name:   strings.ToLower(name),
+		Schema: jsonschema.For[T](),
+	})
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("%w: %v", ErrInvalidResult, err)
+	}
+	return v, nil
+}
+
+type resultRun struct {
+	schema  *ResultSchema
+	mode    ResultMode
+	retries int
+	failed  int
+}
+
+var invalidName = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// name returns the name of the result, restricted to the characters and
+// length accepted by the API.
+func (r *resultRun) name() string {
+	name := strings.Trim(invalidName.ReplaceAllString(r.schema.Name, "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if name == "" {
+		return "result"
+	}
+	return name
+}
+
+func (r *resultRun) apply(params *openai.ChatCompletionNewParams) {
+	switch r.mode {
+	case ResultModeResponseFormat:
+		schema := r.submitSchema()
+		strict, ok := schema.Strict()
+		if ok {
+			schema = strict
+		}
+		params.ResponseFormat = openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](openai.ResponseFormatJSONSchemaParam{
+			Type: openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+			JSONSchema: openai.F(openai.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:        openai.F(r.name()),
+				Description: openai.F(r.schema.Description),
+				Schema:      openai.F[any](schema.Map()),
+				Strict:      openai.F(ok),
+			}),
+		})
+	case ResultModeSubmitTool:
+		description := "Submit the final result. Call this once you are done, instead of answering with text."
+		if r.schema.Description != "" {
+			description += "\n" + r.schema.Description
+		}
+		params.Tools = openai.F(append(params.Tools.Value, openai.ChatCompletionToolParam{
+			Type: openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(openai.FunctionDefinitionParam{
+				Name:        openai.String(SubmitResultTool),
+				Description: openai.String(description),
+				Parameters:  openai.F(openai.FunctionParameters(r.submitSchema().Map())),
+			}),
+		}))
+	}
+}
+
+// submitSchema returns the arguments of the submit tool, or the response
+// format. Both must be objects: other results are wrapped in a `result`
+// property.
+func (r *resultRun) submitSchema() *jsonschema.Schema {
+	if r.wrapped() {
+		return &jsonschema.Schema{
+			Type:       "object",
+			Properties: map[string]*jsonschema.Schema{"result": r.schema.Schema},
+			Required:   []string{"result"},
+		}
+	}
+	return r.schema.Schema
+}
+
+func (r *resultRun) wrapped() bool {
+	return r.schema.Schema.Type != "object"
+}
+
+func (r *resultRun) isSubmit(call ToolCall) bool {
+	return r.mode == ResultModeSubmitTool && call.Name == SubmitResultTool
+}
+
+// answer handles a final text answer. It returns the result if valid, nil if
+// the model was asked to try again.
+func (r *resultRun) answer(a *Agent, reply *Message) (json.RawMessage, error) {
+	if r.mode == ResultModeSubmitTool {
+		return nil, r.retry(a, UserMessage(fmt.Sprintf("You must call the %s tool with your final result.", SubmitResultTool)), "no result submitted")
+	}
+
+	data, err := r.result([]byte(reply.Content))
+	if err != nil {
+		return nil, r.retry(a, UserMessage(fmt.Sprintf("Your answer does not match the %s schema: %v. Answer again.", r.name(), err)), err.Error())
+	}
+	return data, nil
+}
+
+// submit handles a call to the submit tool. It returns the result if valid,
+// nil if the model was asked to try again.
+func (r *resultRun) submit(a *Agent, call ToolCall) (json.RawMessage, error) {
+	data, err := r.result([]byte(call.Arguments))
+	if err != nil {
+		return nil, r.retry(a, ToolMessage(call.ID, fmt.Sprintf("Result rejected: %v. Call %s again with a corrected result.", err, SubmitResultTool)), err.Error())
+	}
+
+	if err := a.record(&Entry{Message: ToolMessage(call.ID, "Result accepted."), Tool: SubmitResultTool}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// result unwraps and validates the result answered or submitted by the model.
+// The null optional properties accepted by strict schemas are dropped.
+func (r *resultRun) result(data []byte) (json.RawMessage, error) {
+	if r.wrapped() {
+		var args struct {
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(data, &args); err == nil && args.Result != nil {
+			data = args.Result
+		}
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, &jsonschema.ValidationError{Problems: []string{fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	v = r.schema.Schema.DropNulls(v)
+	if err := r.schema.Schema.Validate(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// retry tells the model what went wrong, or fails once out of retries.
+func (r *resultRun) retry(a *Agent, feedback *Message, problem string) error {
+	r.failed++
+	if r.failed > r.retries {
+		// Answer pending tool calls to keep the conversation valid.
+		if feedback.Role == RoleTool {
+			if err := a.record(&Entry{Message: ToolMessage(feedback.ToolCallID, "Result rejected: "+problem)}); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrInvalidResult, problem)
+	}
+	return a.record(&Entry{Message: feedback})
+}
+
+// supportsStructuredOutputs reports whether the model supports the
+// `json_schema` response format.
+func supportsStructuredOutputs(model string) bool {
+	if model == openai.ChatModelGPT4o2024_05_13 {
+		return false
+	}
+	for _, prefix := range []string{"gpt-4o", "o1", "o3"} {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/aluzzardi/langdag/tool"
+)
+
+type report struct {
+	Title string `json:"title"`
+	Notes string `json:"notes,omitempty"`
+}
+
+type page[T any] struct {
+	Items []T `json:"items"`
+}
+
+// responseFormat returns the json_schema response format of a request.
+func responseFormat(t *testing.T, req map[string]any) map[string]any {
+	t.Helper()
+	format, _ := req["response_format"].(map[string]any)
+	schema, ok := format["json_schema"].(map[string]any)
+	if !ok {
+		t.Fatalf("no json_schema response format in %v", req)
+	}
+	return schema
+}
+
+func TestRunAsName(t *testing.T) {
+	tests := []struct {
+		name   string
+		run    func(*Agent) error
+		answer string
+		want   string
+		strict bool
+	}{
+		{
+			name:   "struct",
+			run:    func(a *Agent) error { _, err := RunAs[report](context.Background(), a, "go"); return err },
+			answer: `{"title": "t"}`,
+			want:   "report",
+			strict: true,
+		},
+		{
+			name:   "generic",
+			run:    func(a *Agent) error { _, err := RunAs[page[report]](context.Background(), a, "go"); return err },
+			answer: `{"items": []}`,
+			want:   "page",
+			strict: true,
+		},
+		{
+			name:   "map",
+			run:    func(a *Agent) error { _, err := RunAs[map[string]int](context.Background(), a, "go"); return err },
+			answer: `{"a": 1}`,
+			want:   "result",
+		},
+		{
+			name: "invalid characters",
+			run: func(a *Agent) error {
+				_, err := a.RunResult(context.Background(), "go", &ResultSchema{Name: "scan report (v2)", Schema: jsonschema.For[report]()})
+				return err
+			},
+			answer: `{"title": "t"}`,
+			want:   "scan_report_v2",
+			strict: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, model := newFakeModel(t, completion(tt.answer))
+			if err := tt.run(New(client, tool.Tools{})); err != nil {
+				t.Fatal(err)
+			}
+			format := responseFormat(t, model.requests[0])
+			if format["name"] != tt.want {
+				t.Errorf("name = %v, want %s", format["name"], tt.want)
+			}
+			if format["strict"] != tt.strict {
+				t.Errorf("strict = %v, want %v", format["strict"], tt.strict)
+			}
+		})
+	}
+}
+
+func TestRunAsStrict(t *testing.T) {
+	client, model := newFakeModel(t, completion(`{"title": "leaks", "notes": null}`))
+	got, err := RunAs[report](context.Background(), New(client, tool.Tools{}), "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != (report{Title: "leaks"}) {
+		t.Errorf("got %+v", got)
+	}
+
+	schema := responseFormat(t, model.requests[0])["schema"].(map[string]any)
+	if !reflect.DeepEqual(schema["required"], []any{"notes", "title"}) || schema["additionalProperties"] != false {
+		t.Errorf("schema is not strict: %v", schema)
+	}
+}
+
+func TestRunAsWrapped(t *testing.T) {
+	client, model := newFakeModel(t, completion(`{"result": ["a", "b"]}`))
+	got, err := RunAs[[]string](context.Background(), New(client, tool.Tools{}), "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got %v", got)
+	}
+	if schema := responseFormat(t, model.requests[0])["schema"].(map[string]any); schema["type"] != "object" {
+		t.Errorf("response format is not an object: %v", schema)
+	}
+}
+
+func TestRunAsSubmitSkipsLaterCalls(t *testing.T) {
+	client, _ := newFakeModel(t,
+		completion("", SubmitResultTool, `{"title": "t"}`, "later", `{}`),
+	)
+	ran := false
+	after := tool.Func("later", "Must not run.", func(context.Context, struct{}) (string, error) {
+		ran = true
+		return "", nil
+	})
+	a := New(client, tool.Tools{after})
+	a.ResultMode = ResultModeSubmitTool
+	got, err := RunAs[report](context.Background(), a, "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "t" {
+		t.Errorf("got %+v", got)
+	}
+	if ran {
+		t.Error("a tool call following the result ran")
+	}
+	msgs := a.History.Messages()
+	if last := msgs[len(msgs)-1]; last.Role != RoleTool || last.ToolCallID != "call_1" {
+		t.Errorf("the tool call following the result is not answered: %+v", last)
+	}
+}
+
+func TestRunAsRetryTranscriptFailure(t *testing.T) {
+	client, _ := newFakeModel(t,
+		completion("", SubmitResultTool, `{"notes": "no title"}`),
+	)
+	a := New(client, tool.Tools{})
+	a.ResultMode = ResultModeSubmitTool
+	a.ResultRetries = 0
+	// The user message and the tool call are recorded, not the rejection.
+	a.Transcript = NewTranscript(&failingWriter{n: 2})
+	_, err := RunAs[report](context.Background(), a, "go")
+	if !errors.Is(err, ErrTranscript) {
+		t.Errorf("RunAs() = %v, want %v", err, ErrTranscript)
+	}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aluzzardi/langdag/tool"
+)
+
+// SearchToolName is the name of the tool the model calls to find tools that
+// weren't selected.
+const SearchToolName = "search_tools"
+
+// selectorWindow is the number of recent messages, besides tool results,
+// the tools are ranked against.
+const selectorWindow = 4
+
+// ToolSelector sends the model only the tools relevant to the conversation,
+// rather than every tool on every request.
+//
+// Tools are ranked against the latest messages with a tool.Index and the
+// TopK best are sent, along with a search_tools tool the model can call to
+// find the others. Tools found that way are sent for the rest of the
+// conversation.
+type ToolSelector struct {
+	// TopK is the number of ranked tools sent on each request.
+	TopK int
+
+	index  *tool.Index
+	tools  tool.Tools
+	search tool.Tool
+
+	mu    sync.Mutex
+	found map[string]bool
+}
+
+// NewToolSelector returns a selector over tools, which should be the tools
+// of the agent.
+func NewToolSelector(tools tool.Tools, k int) *ToolSelector {
+	s := &ToolSelector{
+		TopK:  k,
+		index: tool.NewIndex(tools),
+		tools: tools,
+		found: map[string]bool{},
+	}
+
+	search := tool.Func(SearchToolName, "Search for tools by keywords when none of the available tools fits the task. The tools found become available.", s.searchTools)
+	search.SetSafety(tool.ReadOnly)
+	s.search = search
+	return s
+}
+
+type searchArgs struct {
+	Query string `json:"query" description:"Keywords describing what the tool should do, e.g. \"close issue\"."`
+	Limit int    `json:"limit,omitempty" description:"Maximum number of tools to return. Defaults to 5."`
+}
+
+type searchResult struct {
+	Tools []foundTool `json:"tools"`
+}
+
+type foundTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (s *ToolSelector) searchTools(_ context.Context, args searchArgs) (*searchResult, error) {
+	if args.Limit <= 0 {
+		args.Limit = 5
+	}
+
+	result := &searchResult{Tools: []foundTool{}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.index.Search(args.Query, args.Limit) {
+		s.found[t.Name()] = true
+		result.Tools = append(result.Tools, foundTool{Name: t.Name(), Description: t.Description()})
+	}
+	return result, nil
+}
+
+// Select returns the tools to send with a request continuing msgs.
+func (s *ToolSelector) Select(msgs []*Message) tool.Tools {
+	if len(s.tools) <= s.TopK {
+		return s.tools
+	}
+
+	selected := map[string]bool{}
+	for _, t := range s.index.Search(query(msgs), s.TopK) {
+		selected[t.Name()] = true
+	}
+	s.mu.Lock()
+	for name := range s.found {
+		selected[name] = true
+	}
+	s.mu.Unlock()
+
+	// Keep the order of the tools: it doesn't depend on the conversation,
+	// which is friendlier to prompt caching.
+	tools := tool.Tools{}
+	for _, t := range s.tools {
+		if selected[t.Name()] {
+			tools = append(tools, t)
+		}
+	}
+	return append(tools, s.search)
+}
+
+// Tools returns the tools calls are dispatched to: the tools of the selector
+// and search_tools.
+func (s *ToolSelector) Tools() tool.Tools {
+	return append(s.tools[:len(s.tools):len(s.tools)], s.search)
+}
+
+// query is the text of the latest messages. Tool results are left out: they
+// are mostly data and would drown the request of the user.
+func query(msgs []*Message) string {
+	parts := []string{}
+	for i := len(msgs) - 1; i >= 0 && len(parts) < selectorWindow; i-- {
+		m := msgs[i]
+		switch m.Role {
+		case RoleUser, RoleAssistant:
+			for _, call := range m.ToolCalls {
+				parts = append(parts, call.Name)
+			}
+			if m.Content != "" {
+				parts = append(parts, m.Content)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
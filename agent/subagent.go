@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/aluzzardi/langdag/tool"
+)
+
+// AsTool exposes the agent as a tool that other agents delegate tasks to,
+// named "agent_<name>". The tool takes the task as `instructions` and returns
+// the final answer of the agent.
+//
+// Every call runs on a fresh copy of the conversation as it is now, typically
+// just a system prompt: configure the agent before calling AsTool. The
+// entries of the sub-agent are nested in the transcript of the calling agent,
+// and its usage is accounted for by the meters of the calling agents as well
+// as its own: their budgets apply to the sub-agent.
+func (a *Agent) AsTool(name, description string) *tool.FuncTool {
+	base := a.History.Messages()
+
+	t := tool.NewFunction("agent", name, description, []tool.Arg{
+		{
+			Name:        "instructions",
+			Description: "The task to carry out, with all the context needed: the agent doesn't see your conversation.",
+		},
+	}, tool.TransportFunc(func(ctx context.Context, inv *tool.Invocation) (json.RawMessage, error) {
+		var args struct {
+			Instructions string `json:"instructions"`
+		}
+		if err := json.Unmarshal([]byte(inv.Arguments), &args); err != nil {
+			return nil, err
+		}
+
+		sub := *a
+		sub.History = NewHistory(a.History.MaxTokens, a.History.Strategies...)
+		sub.History.Append(base...)
+		// The meters of the calling agents are passed along with ctx: they
+		// account for the usage without the sub-agent starting turns on them.
+		if parent := callerOf(ctx); parent != nil && parent.Transcript != nil {
+			sub.Transcript = parent.Transcript.Nested(name)
+		}
+
+		reply, err := sub.Run(ctx, args.Instructions)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", name, err)
+		}
+		return json.Marshal(map[string]string{"answer": reply.Content})
+	}))
+
+	// The sub-agent may do anything its tools do.
+	safety := tool.ReadOnly
+	for _, t := range a.Tools {
+		if s := tool.SafetyOf(t); s.AtLeast(safety) {
+			safety = s
+		}
+	}
+	t.SetSafety(safety)
+
+	return t
+}
+
+type callerKey struct{}
+
+// callerOf returns the agent making the tool call running with ctx.
+func callerOf(ctx context.Context) *Agent {
+	a, _ := ctx.Value(callerKey{}).(*Agent)
+	return a
+}
+
+type metersKey struct{}
+
+// meters returns the meters accounting for the model calls of the agent: its
+// own and those of the agents it runs on behalf of, without duplicates.
+func (a *Agent) meters(ctx context.Context) []*Meter {
+	meters := []*Meter{}
+	if a.Meter != nil {
+		meters = append(meters, a.Meter)
+	}
+	ancestors, _ := ctx.Value(metersKey{}).([]*Meter)
+	for _, m := range ancestors {
+		if !slices.Contains(meters, m) {
+			meters = append(meters, m)
+		}
+	}
+	return meters
+}
@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// Entry is a single line of a transcript.
+type Entry struct {
+	Time time.Time `json:"time"`
+
+	// Session is set on the entry that starts (or resumes) a session.
+	Session *SessionInfo `json:"session,omitempty"`
+
+	// Message is set on entries recording a conversation message.
+	Message *Message `json:"message,omitempty"`
+
+	// Duration is how long it took to produce Message: the model call for
+	// assistant messages, the tool call for tool messages.
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Model describes the completion that produced an assistant message.
+	Model *ModelInfo `json:"model,omitempty"`
+
+	// Tool is the name of the tool that produced a tool message.
+	Tool string `json:"tool,omitempty"`
+
+	// Error is set when a tool call failed.
+	Error string `json:"error,omitempty"`
+
+	// Agent is set on entries of sub-agents, nested in the transcript of
+	// their caller. Nested sub-agents are separated by slashes.
+	Agent string `json:"agent,omitempty"`
+}
+
+type SessionInfo struct {
+	ID    string   `json:"id,omitempty"`
+	Model string   `json:"model,omitempty"`
+	Tools []string `json:"tools,omitempty"`
+}
+
+type ModelInfo struct {
+	Model             string `json:"model"`
+	CompletionID      string `json:"completion_id,omitempty"`
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	FinishReason      string `json:"finish_reason,omitempty"`
+	PromptTokens      int64  `json:"prompt_tokens,omitempty"`
+	CompletionTokens  int64  `json:"completion_tokens,omitempty"`
+}
+
+func NewModelInfo(completion *openai.ChatCompletion) *ModelInfo {
+	info := &ModelInfo{
+		Model:             completion.Model,
+		CompletionID:      completion.ID,
+		SystemFingerprint: completion.SystemFingerprint,
+		PromptTokens:      completion.Usage.PromptTokens,
+		CompletionTokens:  completion.Usage.CompletionTokens,
+	}
+	if len(completion.Choices) > 0 {
+		info.FinishReason = string(completion.Choices[0].FinishReason)
+	}
+	return info
+}
+
+// Transcript records a conversation as JSON lines, one Entry per line.
+//
+// Entries are written as they happen so that a transcript is usable even if
+// the process dies mid-session.
+type Transcript struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+
+	// parent and agent are set on nested transcripts.
+	parent *Transcript
+	agent  string
+}
+
+func NewTranscript(w io.Writer) *Transcript {
+	return &Transcript{w: w}
+}
+
+// OpenTranscript opens the transcript at path for appending, creating it if
+// needed. Entries already present in the file are returned so the session can
+// be resumed.
+func OpenTranscript(path string) (*Transcript, []*Entry, error) {
+	entries, err := LoadTranscript(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Transcript{w: f, closer: f}, entries, nil
+}
+
+func (t *Transcript) Record(e *Entry) error {
+	if t == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if t.parent != nil {
+		e.Agent = join(t.agent, e.Agent)
+		return t.parent.Record(e)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err = t.w.Write(append(data, '\n'))
+	return err
+}
+
+// Nested returns a transcript recording the entries of a sub-agent in t.
+func (t *Transcript) Nested(agent string) *Transcript {
+	if t == nil {
+		return nil
+	}
+	return &Transcript{parent: t, agent: agent}
+}
+
+func join(parent, child string) string {
+	if child == "" {
+		return parent
+	}
+	return parent + "/" + child
+}
+
+func (t *Transcript) Close() error {
+	if t == nil || t.closer == nil {
+		return nil
+	}
+	return t.closer.Close()
+}
+
+func LoadTranscript(path string) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadTranscript(f)
+}
+
+func ReadTranscript(r io.Reader) ([]*Entry, error) {
+	entries := []*Entry{}
+
+	scanner := bufio.NewScanner(r)
+	// Tool results can be large, don't limit lines to the default 64k.
+	scanner.Buffer(nil, 64<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("transcript line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// TranscriptMessages returns the conversation messages recorded in entries.
+//
+// If the session was interrupted while tools were running, the unanswered
+// tool calls are dropped: the model API rejects tool calls without results.
+func TranscriptMessages(entries []*Entry) []*Message {
+	msgs := []*Message{}
+	for _, e := range entries {
+		if e.Message != nil && e.Agent == "" {
+			msgs = append(msgs, e.Message)
+		}
+	}
+
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == RoleTool {
+			continue
+		}
+		if len(msgs[i].ToolCalls) > len(msgs)-i-1 {
+			msgs = msgs[:i]
+		}
+		break
+	}
+	return msgs
+}
@@ -0,0 +1,315 @@
+package agent
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go"
+)
+
+// Usage counts the tokens consumed by model calls.
+type Usage struct {
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (u *Usage) Tokens() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+func (u *Usage) add(o Usage) {
+	u.Calls += o.Calls
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.Cost += o.Cost
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("%d calls, %d prompt + %d completion tokens, $%.4f", u.Calls, u.PromptTokens, u.CompletionTokens, u.Cost)
+}
+
+// Price is the cost of a model in dollars per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// PriceTable maps model names to prices.
+type PriceTable map[string]Price
+
+// DefaultPrices are the public list prices of common OpenAI models.
+var DefaultPrices = PriceTable{
+	"gpt-4o":        {Prompt: 2.50, Completion: 10.00},
+	"gpt-4o-mini":   {Prompt: 0.15, Completion: 0.60},
+	"gpt-4-turbo":   {Prompt: 10.00, Completion: 30.00},
+	"gpt-3.5-turbo": {Prompt: 0.50, Completion: 1.50},
+	"o1":            {Prompt: 15.00, Completion: 60.00},
+	"o1-mini":       {Prompt: 3.00, Completion: 12.00},
+}
+
+// LoadPriceTable reads a JSON price table, e.g.
+//
+//	{"gpt-4o": {"prompt": 2.5, "completion": 10}}
+func LoadPriceTable(path string) (PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	prices := PriceTable{}
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("unable to load price table %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+// Lookup returns the price of a model.
+//
+// Completions report dated model names (gpt-4o-2024-08-06): when there's no
+// exact match, the longest entry prefixing the name is used.
+func (p PriceTable) Lookup(model string) (Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range p {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return p[best], true
+}
+
+func (p PriceTable) Cost(model string, promptTokens, completionTokens int64) float64 {
+	price, ok := p.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1_000_000
+}
+
+// Budget limits the resources a Meter may account for. Zero values are
+// unlimited.
+type Budget struct {
+	MaxTokens int64
+	MaxCost   float64
+}
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// TurnUsage is the usage caused by a single user request.
+type TurnUsage struct {
+	Label string
+	Usage Usage
+
+	// Steps is the usage of each model call of the turn: the first call
+	// answers the request, each following one a round of tool calls.
+	Steps []Usage
+}
+
+// Meter accounts for the usage of model calls.
+type Meter struct {
+	Prices PriceTable
+	Budget Budget
+
+	mu      sync.Mutex
+	total   Usage
+	byModel map[string]*Usage
+	turns   []*TurnUsage
+
+	// toolTokens is the estimated number of prompt tokens taken by the
+	// output of each tool, counted every time the output is sent back.
+	toolTokens map[string]int64
+}
+
+func NewMeter(prices PriceTable, budget Budget) *Meter {
+	if prices == nil {
+		prices = DefaultPrices
+	}
+	return &Meter{
+		Prices:     prices,
+		Budget:     budget,
+		byModel:    map[string]*Usage{},
+		toolTokens: map[string]int64{},
+	}
+}
+
+// StartTurn starts attributing usage to a new turn.
+func (m *Meter) StartTurn(label string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.turns = append(m.turns, &TurnUsage{Label: label})
+}
+
+// Record accounts for a completion obtained by sending prompt to the model.
+//
+// It returns ErrBudgetExceeded once the budget is exhausted.
+func (m *Meter) Record(completion *openai.ChatCompletion, prompt []*Message) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := Usage{
+		Calls:            1,
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+		Cost:             m.Prices.Cost(completion.Model, completion.Usage.PromptTokens, completion.Usage.CompletionTokens),
+	}
+
+	m.total.add(u)
+	if m.byModel[completion.Model] == nil {
+		m.byModel[completion.Model] = &Usage{}
+	}
+	m.byModel[completion.Model].add(u)
+
+	if len(m.turns) == 0 {
+		m.turns = append(m.turns, &TurnUsage{})
+	}
+	turn := m.turns[len(m.turns)-1]
+	turn.Usage.add(u)
+	turn.Steps = append(turn.Steps, u)
+
+	toolNames := map[string]string{}
+	for _, msg := range prompt {
+		for _, call := range msg.ToolCalls {
+			toolNames[call.ID] = call.Name
+		}
+		if msg.Role == RoleTool {
+			m.toolTokens[toolNames[msg.ToolCallID]] += int64(EstimateTokens(msg.Content))
+		}
+	}
+
+	return m.check()
+}
+
+// Check returns ErrBudgetExceeded if the budget is already exhausted.
+func (m *Meter) Check() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.check()
+}
+
+func (m *Meter) check() error {
+	if m.Budget.MaxTokens > 0 && m.total.Tokens() > m.Budget.MaxTokens {
+		return fmt.Errorf("%w: used %d tokens out of %d", ErrBudgetExceeded, m.total.Tokens(), m.Budget.MaxTokens)
+	}
+	if m.Budget.MaxCost > 0 && m.total.Cost > m.Budget.MaxCost {
+		return fmt.Errorf("%w: spent $%.4f out of $%.4f", ErrBudgetExceeded, m.total.Cost, m.Budget.MaxCost)
+	}
+	return nil
+}
+
+func (m *Meter) Total() Usage {
+	if m == nil {
+		return Usage{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+func (m *Meter) Turns() []TurnUsage {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	turns := make([]TurnUsage, 0, len(m.turns))
+	for _, t := range m.turns {
+		turns = append(turns, *t)
+	}
+	return turns
+}
+
+// ToolTokens is the estimated prompt tokens taken by the outputs of a tool.
+type ToolTokens struct {
+	Tool   string
+	Tokens int64
+}
+
+// TopTools returns the tools whose outputs took the most prompt tokens.
+func (m *Meter) TopTools(n int) []ToolTokens {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tools := make([]ToolTokens, 0, len(m.toolTokens))
+	for name, tokens := range m.toolTokens {
+		tools = append(tools, ToolTokens{Tool: name, Tokens: tokens})
+	}
+	slices.SortFunc(tools, func(a, b ToolTokens) int {
+		return cmp.Compare(b.Tokens, a.Tokens)
+	})
+	if n > 0 && len(tools) > n {
+		tools = tools[:n]
+	}
+	return tools
+}
+
+// Report renders a human readable summary of the usage.
+func (m *Meter) Report() string {
+	if m == nil {
+		return "No usage recorded.\n"
+	}
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "Total: %s\n", m.Total())
+
+	m.mu.Lock()
+	models := make([]string, 0, len(m.byModel))
+	for model := range m.byModel {
+		models = append(models, model)
+	}
+	slices.Sort(models)
+	for _, model := range models {
+		fmt.Fprintf(sb, "  %s: %s\n", model, m.byModel[model])
+	}
+	m.mu.Unlock()
+
+	turns := m.Turns()
+	if len(turns) > 0 {
+		fmt.Fprintf(sb, "Turns:\n")
+	}
+	for i, turn := range turns {
+		label := strings.ReplaceAll(turn.Label, "\n", " ")
+		if len(label) > 60 {
+			n := 57
+			for n > 0 && !utf8.RuneStart(label[n]) {
+				n--
+			}
+			label = label[:n] + "..."
+		}
+		fmt.Fprintf(sb, "  #%d %s\n", i+1, label)
+		fmt.Fprintf(sb, "     %s over %d steps\n", turn.Usage, len(turn.Steps))
+	}
+
+	top := m.TopTools(5)
+	if len(top) > 0 {
+		fmt.Fprintf(sb, "Prompt tokens from tool outputs (estimated):\n")
+	}
+	for _, t := range top {
+		fmt.Fprintf(sb, "  %s: %d\n", t.Tool, t.Tokens)
+	}
+
+	return sb.String()
+}
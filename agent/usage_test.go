@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReportLabel(t *testing.T) {
+	m := NewMeter(nil, Budget{})
+	m.StartTurn(strings.Repeat("é", 40))
+	report := m.Report()
+	if !utf8.ValidString(report) {
+		t.Errorf("invalid UTF-8 in report %q", report)
+	}
+	if !strings.Contains(report, "é...") {
+		t.Errorf("label not truncated in report %q", report)
+	}
+}
+
+func TestNilMeter(t *testing.T) {
+	var m *Meter
+	if got := m.Total(); got != (Usage{}) {
+		t.Errorf("Total() = %s", got)
+	}
+	if m.Turns() != nil || m.TopTools(5) != nil {
+		t.Error("nil meter has turns or tools")
+	}
+	if m.Report() == "" {
+		t.Error("empty report")
+	}
+}
@@ -0,0 +1,367 @@
+// Package cassette records the interactions of an agent with the model and
+// its tools, and replays them without network or engine access.
+//
+// A cassette captures, in order, every model API request along with its
+// response and every tool invocation along with its GraphQL query and
+// response. The definitions of the tools are stored as well so that a replay
+// doesn't need to load modules.
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aluzzardi/langdag/tool"
+)
+
+type Mode int
+
+const (
+	// ModeRecord forwards every interaction and captures it.
+	ModeRecord Mode = iota
+	// ModeReplay serves captured interactions back.
+	ModeReplay
+)
+
+type Kind string
+
+const (
+	KindModel Kind = "model"
+	KindTool  Kind = "tool"
+)
+
+type Interaction struct {
+	Kind Kind `json:"kind"`
+
+	// Model requests
+	Method   string          `json:"method,omitempty"`
+	URL      string          `json:"url,omitempty"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response *Response       `json:"response,omitempty"`
+
+	// Tool invocations
+	Tool      string          `json:"tool,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Query     string          `json:"query,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+
+	// Failure describes the error of a failed invocation, to return the same
+	// error when replaying. Error is kept for reading.
+	Failure *Failure `json:"failure,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+type Cassette struct {
+	Tools        json.RawMessage `json:"tools,omitempty"`
+	Interactions []*Interaction  `json:"interactions"`
+
+	mu   sync.Mutex
+	path string
+	mode Mode
+	pos  int
+
+	// Transport is used to forward model requests while recording. Defaults
+	// to http.DefaultTransport.
+	Transport http.RoundTripper `json:"-"`
+}
+
+// Open prepares a cassette stored at path.
+//
+// In record mode the cassette starts empty and is written by Save. In replay
+// mode it is loaded from path.
+func Open(path string, mode Mode) (*Cassette, error) {
+	c := &Cassette{
+		path: path,
+		mode: mode,
+	}
+	if mode == ModeRecord {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("unable to load cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Cassette) Mode() Mode {
+	return c.mode
+}
+
+// Save writes the cassette to disk. It's a no-op in replay mode.
+func (c *Cassette) Save() error {
+	if c.mode != ModeRecord {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// Close saves a recorded cassette. When replaying, it reports interactions
+// that were recorded but never requested.
+func (c *Cassette) Close() error {
+	if c.mode == ModeRecord {
+		return c.Save()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if remaining := len(c.Interactions) - c.pos; remaining > 0 {
+		return fmt.Errorf("cassette: %d recorded interactions were not replayed", remaining)
+	}
+	return nil
+}
+
+// LoadTools returns the tools the session uses.
+//
+// When recording, the tools are loaded by calling load and their calls are
+// captured. When replaying, load is not called: the tools are restored from
+// the cassette and their calls are served from it.
+func (c *Cassette) LoadTools(load func() (tool.Tools, error)) (tool.Tools, error) {
+	if c.mode == ModeReplay {
+		return tool.Restore(c.Tools, tool.TransportFunc(c.replayTool))
+	}
+
+	tools, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := tools.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.Tools = snapshot
+	c.mu.Unlock()
+
+	tools.WrapTransport(func(next tool.Transport) tool.Transport {
+		return tool.TransportFunc(func(ctx context.Context, inv *tool.Invocation) (json.RawMessage, error) {
+			return c.recordTool(ctx, next, inv)
+		})
+	})
+	return tools, nil
+}
+
+// HTTPClient returns a client to be used for model API requests, e.g. with
+// option.WithHTTPClient.
+func (c *Cassette) HTTPClient() *http.Client {
+	return &http.Client{Transport: roundTripper{c}}
+}
+
+func (c *Cassette) recordTool(ctx context.Context, next tool.Transport, inv *tool.Invocation) (json.RawMessage, error) {
+	start := time.Now()
+	result, err := next.Execute(ctx, inv)
+
+	i := &Interaction{
+		Kind:      KindTool,
+		Tool:      inv.Tool,
+		Arguments: rawJSON(inv.Arguments),
+		Query:     inv.Query,
+		Result:    result,
+	}
+	if err != nil {
+		i.Error = err.Error()
+		i.Failure = newFailure(ctx, start, err)
+	}
+	c.append(i)
+
+	return result, err
+}
+
+// replayTool serves a recorded tool invocation.
+//
+// Invocations are matched on the tool name and arguments rather than on the
+// query, which embeds session-specific IDs (secrets, for instance).
+func (c *Cassette) replayTool(_ context.Context, inv *tool.Invocation) (json.RawMessage, error) {
+	actual := fmt.Sprintf("%s(%s)", inv.Tool, inv.Arguments)
+	i, err := c.next(KindTool, actual, func(i *Interaction) bool {
+		return i.Tool == inv.Tool && equalJSON(i.Arguments, []byte(inv.Arguments))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if i.Failure != nil {
+		return nil, i.Failure.err(inv.Tool)
+	}
+	if i.Error != "" {
+		return nil, errors.New(i.Error)
+	}
+
+	// The cassette file is indented: give back the result as it was received.
+	result := new(bytes.Buffer)
+	if err := json.Compact(result, i.Result); err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+func (c *Cassette) append(i *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, i)
+}
+
+// next consumes the next recorded interaction if it is of the given kind and
+// matches. The cassette doesn't advance on divergence, so that client-side
+// retries fail the same way.
+func (c *Cassette) next(kind Kind, actual string, match func(*Interaction) bool) (*Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pos >= len(c.Interactions) {
+		return nil, &DivergenceError{
+			Position: c.pos,
+			Expected: "end of cassette",
+			Actual:   fmt.Sprintf("%s %s", kind, actual),
+		}
+	}
+	i := c.Interactions[c.pos]
+	if i.Kind != kind || !match(i) {
+		return nil, &DivergenceError{
+			Position: c.pos,
+			Expected: fmt.Sprintf("%s %s", i.Kind, i.describe()),
+			Actual:   fmt.Sprintf("%s %s", kind, actual),
+		}
+	}
+	c.pos++
+	return i, nil
+}
+
+func (i *Interaction) describe() string {
+	if i.Kind == KindTool {
+		return fmt.Sprintf("%s(%s)", i.Tool, i.Arguments)
+	}
+	return fmt.Sprintf("%s %s %s", i.Method, i.URL, i.Request)
+}
+
+// DivergenceError is returned when replayed requests don't match the
+// recording.
+type DivergenceError struct {
+	Position int
+	Expected string
+	Actual   string
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("cassette diverged at interaction %d: expected %s, got %s", e.Position, e.Expected, e.Actual)
+}
+
+type roundTripper struct {
+	c *Cassette
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if rt.c.mode == ModeReplay {
+		return rt.replay(req, body)
+	}
+	return rt.record(req, body)
+}
+
+func (rt roundTripper) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := rt.c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	rt.c.append(&Interaction{
+		Kind:    KindModel,
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Request: rawJSON(string(body)),
+		Response: &Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       string(respBody),
+		},
+	})
+
+	return resp, nil
+}
+
+func (rt roundTripper) replay(req *http.Request, body []byte) (*http.Response, error) {
+	actual := fmt.Sprintf("%s %s %s", req.Method, req.URL, body)
+	i, err := rt.c.next(KindModel, actual, func(i *Interaction) bool {
+		return i.Method == req.Method && i.URL == req.URL.String() && equalJSON(i.Request, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(i.Response.StatusCode),
+		StatusCode: i.Response.StatusCode,
+		Header:     i.Response.Header.Clone(),
+		Body:       io.NopCloser(bytes.NewReader([]byte(i.Response.Body))),
+		Request:    req,
+	}, nil
+}
+
+// rawJSON keeps s as is if it's valid JSON, and encodes it as a string
+// otherwise.
+func rawJSON(s string) json.RawMessage {
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	data, _ := json.Marshal(s)
+	return data
+}
+
+func equalJSON(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil {
+		return false
+	}
+	if err := json.Unmarshal(rawJSON(string(b)), &vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
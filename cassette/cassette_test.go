@@ -0,0 +1,333 @@
+package cassette_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aluzzardi/langdag/agent"
+	"github.com/aluzzardi/langdag/cassette"
+	"github.com/aluzzardi/langdag/retry"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+var update = flag.Bool("update", false, "record the cassettes of testdata again")
+
+// mission is the cassette of a mission whose tool calls fail: a command
+// exits with an error and a call times out before being retried.
+const mission = "testdata/mission.json"
+
+type issueArgs struct {
+	Number int `json:"number"`
+}
+
+// missionTools are the tools of the mission, as they behave when recording.
+func missionTools() tool.Tools {
+	var slow atomic.Int32
+	return tool.Tools{
+		tool.Func("github_issue-comment", "Comment on an issue.", func(_ context.Context, args issueArgs) (string, error) {
+			if args.Number == 404 {
+				return "", &tool.ExecError{
+					Tool:     "github_issue-comment",
+					Command:  "gh issue comment 404",
+					ExitCode: 1,
+					Stderr:   "GraphQL: Could not resolve to an issue or pull request with the number of 404.",
+					Err:      errors.New(`process "gh issue comment 404" did not complete successfully: exit code: 1`),
+				}
+			}
+			return "commented", nil
+		}),
+		tool.Func("github_issue-view", "View an issue.", func(ctx context.Context, args issueArgs) (string, error) {
+			if slow.Add(1) == 1 {
+				<-ctx.Done()
+				return "", fmt.Errorf("view issue: %w", ctx.Err())
+			}
+			return "issue 42: the build is broken", nil
+		}),
+	}
+}
+
+// baseURL is the URL of the model API recorded in cassettes.
+const baseURL = "http://model.test/v1"
+
+// model answers the requests of the mission, sent to baseURL.
+func model(t *testing.T) http.RoundTripper {
+	replies := []map[string]any{
+		completion("", "github_issue-comment", `{"number":404}`),
+		completion("", "github_issue-view", `{"number":42}`),
+		completion("", "github_issue-comment", `{"number":42}`),
+		completion("Commented on issue 42."),
+	}
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(n.Add(1)) - 1
+		if i >= len(replies) {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(replies[i])
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	return redirect{target}
+}
+
+// redirect sends requests to target.
+type redirect struct{ target *url.URL }
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func completion(content string, call ...string) map[string]any {
+	message := map[string]any{"role": "assistant", "content": content}
+	if len(call) > 0 {
+		message["tool_calls"] = []any{map[string]any{
+			"id":       "call_" + call[1],
+			"type":     "function",
+			"function": map[string]any{"name": call[0], "arguments": call[1]},
+		}}
+	}
+	return map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"model":   "gpt-4o",
+		"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": "stop"}},
+		"usage":   map[string]any{"prompt_tokens": 100, "completion_tokens": 10, "total_tokens": 110},
+	}
+}
+
+// run runs the mission against the tape.
+func run(t *testing.T, tape *cassette.Cassette) (*agent.Message, []*agent.Message) {
+	t.Helper()
+	tools, err := tape.LoadTools(func() (tool.Tools, error) { return missionTools(), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools.Retry(retry.Policy{Timeout: 100 * time.Millisecond, MaxRetries: 1, InitialDelay: time.Millisecond}, nil)
+
+	client := openai.NewClient(
+		option.WithBaseURL(baseURL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(tape.HTTPClient()),
+	)
+	a := agent.New(client, tools)
+	reply, err := a.Run(context.Background(), "comment on the issue about the build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply, a.History.Messages()
+}
+
+func TestReplay(t *testing.T) {
+	if *update {
+		tape, err := cassette.Open(mission, cassette.ModeRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tape.Transport = model(t)
+		run(t, tape)
+		if err := tape.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tape, err := cassette.Open(mission, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing listens on baseURL: everything is served by the cassette.
+	reply, msgs := run(t, tape)
+	if err := tape.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "Commented on issue 42." {
+		t.Errorf("reply = %q", reply.Content)
+	}
+
+	var failure map[string]*agent.ToolFailure
+	if err := json.Unmarshal([]byte(msgs[2].Content), &failure); err != nil {
+		t.Fatalf("tool message %q: %v", msgs[2].Content, err)
+	}
+	if f := failure["error"]; f.Kind != agent.FailureExec || f.Command != "gh issue comment 404" {
+		t.Errorf("unexpected failure %+v", f)
+	}
+}
+
+func TestReplayFailure(t *testing.T) {
+	// Replay the tool calls of the mission only.
+	data, err := os.ReadFile(mission)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded cassette.Cassette
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		t.Fatal(err)
+	}
+	calls := []*cassette.Interaction{}
+	for _, i := range recorded.Interactions {
+		if i.Kind == cassette.KindTool && i.Failure != nil {
+			calls = append(calls, i)
+		}
+	}
+	recorded.Interactions = calls
+	data, err = json.Marshal(&recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "failures.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tape, err := cassette.Open(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools, err := tape.LoadTools(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tools.Get("github_issue-comment").Call(context.Background(), `{"number":404}`)
+	var exec *tool.ExecError
+	if !errors.As(err, &exec) || exec.ExitCode != 1 || exec.Command != "gh issue comment 404" {
+		t.Errorf("Call() = %v, want an ExecError", err)
+	}
+
+	_, err = tools.Get("github_issue-view").Call(context.Background(), `{"number":42}`)
+	var timeout *retry.TimeoutError
+	if !errors.As(err, &timeout) || timeout.Timeout != 100*time.Millisecond || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Call() = %v, want a TimeoutError", err)
+	}
+	if !retry.IsTransient(err) {
+		t.Errorf("replayed timeout isn't transient")
+	}
+}
+
+// scanPR is the cassette of a real mission, recorded against the github and
+// trufflehog modules, a model and GitHub by running
+//
+//	langdag serve -record cassette/testdata/scan_pr_for_secrets.json \
+//		"When a pull request is opened, scan it for leaked secrets and comment with your findings." \
+//		./modules/github ./modules/trufflehog
+//
+// and delivering the pull_request webhook of a pull request of
+// trufflesecurity/test_keys. Record it again when the modules change.
+const scanPR = "testdata/scan_pr_for_secrets.json"
+
+// recordedMission returns the model, the system messages and the input of
+// the first model request of a cassette recorded by serve.
+func recordedMission(t *testing.T, tape *cassette.Cassette) (baseURL, model string, system []*agent.Message, input string) {
+	t.Helper()
+	if len(tape.Interactions) == 0 || tape.Interactions[0].Kind != cassette.KindModel {
+		t.Fatal("the cassette doesn't start with a model request")
+	}
+	first := tape.Interactions[0]
+	var req struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(first.Request, &req); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range req.Messages {
+		text := ""
+		if err := json.Unmarshal(m.Content, &text); err != nil {
+			var parts []struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal(m.Content, &parts); err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range parts {
+				text += p.Text
+			}
+		}
+		switch m.Role {
+		case "system":
+			system = append(system, agent.SystemMessage(text))
+		case "user":
+			input = text
+		}
+	}
+	return strings.TrimSuffix(first.URL, "chat/completions"), req.Model, system, input
+}
+
+func TestReplayRecordedMission(t *testing.T) {
+	if _, err := os.Stat(scanPR); errors.Is(err, os.ErrNotExist) {
+		t.Skipf("%s hasn't been recorded: see scanPR", scanPR)
+	}
+	tape, err := cassette.Open(scanPR, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL, model, system, input := recordedMission(t, tape)
+
+	// The tools are restored from the cassette: no engine is needed.
+	tools, err := tape.LoadTools(func() (tool.Tools, error) { return nil, errors.New("replaying") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recorded failures are retried like serve does, without waiting.
+	policy := retry.DefaultPolicy
+	policy.MaxRetries = 2
+	policy.InitialDelay = time.Millisecond
+	policies := map[string]retry.Policy{}
+	for _, t := range tools {
+		p := policy
+		if !tool.SafeToRetry(t) {
+			p.MaxRetries = 0
+		}
+		policies[t.Name()] = p
+	}
+	tools.Retry(policy, policies)
+	client := openai.NewClient(
+		option.WithBaseURL(baseURL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(tape.HTTPClient()),
+	)
+
+	// Configured like serve.
+	a := agent.New(client, tools)
+	a.Model = model
+	a.History = agent.NewHistory(64000,
+		agent.TruncateToolResults{MaxChars: 2000, KeepRecent: 2},
+		agent.DropOldestTurns{},
+	)
+	if err := a.Append(system...); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := a.Run(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tape.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content == "" {
+		t.Error("empty final answer")
+	}
+}
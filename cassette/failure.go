@@ -0,0 +1,117 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/aluzzardi/langdag/retry"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Kinds of recorded failures.
+const (
+	FailureExec    = "exec"
+	FailureQuery   = "query"
+	FailureTimeout = "timeout"
+	FailureNetwork = "network"
+	FailureError   = "error"
+)
+
+// Failure is a recorded tool error. It keeps the type and the fields of the
+// error so that replays return an error that is reported to the model and
+// retried as the original one was.
+//
+// Arguments are validated before calls reach the transport, when replaying
+// too: argument errors are never recorded.
+type Failure struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+
+	// Exec errors
+	Command  string `json:"command,omitempty"`
+	ExitCode int    `json:"exitCode,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+
+	// Query errors
+	Errors gqlerror.List `json:"errors,omitempty"`
+
+	// Timeout is the timeout of the attempt that timed out.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// newFailure records err, returned by a call started at start.
+func newFailure(ctx context.Context, start time.Time, err error) *Failure {
+	f := &Failure{Kind: FailureError, Message: err.Error()}
+
+	var (
+		exec   *tool.ExecError
+		query  *tool.QueryError
+		netErr net.Error
+	)
+	switch {
+	case errors.As(err, &exec):
+		f.Kind = FailureExec
+		f.Command = exec.Command
+		f.ExitCode = exec.ExitCode
+		f.Stdout = exec.Stdout
+		f.Stderr = exec.Stderr
+		if exec.Err != nil {
+			f.Message = exec.Err.Error()
+		}
+	case errors.As(err, &query):
+		f.Kind = FailureQuery
+		f.Errors = query.Errors
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// The attempt ran out of time: retry policies wrap the error into a
+		// retry.TimeoutError, of the timeout the deadline was set to.
+		f.Kind = FailureTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			f.Timeout = deadline.Sub(start).Round(time.Millisecond)
+		}
+	case errors.As(err, &netErr):
+		f.Kind = FailureNetwork
+	}
+	return f
+}
+
+// err rebuilds the recorded error of a call of the tool name.
+func (f *Failure) err(name string) error {
+	switch f.Kind {
+	case FailureExec:
+		return &tool.ExecError{
+			Tool:     name,
+			Command:  f.Command,
+			ExitCode: f.ExitCode,
+			Stdout:   f.Stdout,
+			Stderr:   f.Stderr,
+			Err:      errors.New(f.Message),
+		}
+	case FailureQuery:
+		return &tool.QueryError{Tool: name, Errors: f.Errors}
+	case FailureTimeout:
+		// Nothing times out when replaying: return the error retry policies
+		// would have returned.
+		return &retry.TimeoutError{Timeout: f.Timeout, Err: &deadlineError{f.Message}}
+	case FailureNetwork:
+		return &networkError{f.Message}
+	}
+	return errors.New(f.Message)
+}
+
+// deadlineError is a recorded error caused by a deadline.
+type deadlineError struct{ msg string }
+
+func (e *deadlineError) Error() string { return e.msg }
+func (e *deadlineError) Unwrap() error { return context.DeadlineExceeded }
+
+// networkError is a recorded network error, which retry policies consider
+// transient.
+type networkError struct{ msg string }
+
+func (e *networkError) Error() string   { return e.msg }
+func (e *networkError) Timeout() bool   { return false }
+func (e *networkError) Temporary() bool { return true }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aluzzardi/langdag/tool"
+)
+
+func call(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	arguments := fs.String("json", "{}", "arguments of the call as a JSON object, - to read them from stdin")
+	args, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	name, mods := args[0], args[1:]
+	defer cfg.close()
+
+	if *arguments == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		*arguments = string(data)
+	}
+
+	tools, err := cfg.load(ctx, mods)
+	if err != nil {
+		return err
+	}
+	t := tools.Get(name)
+	if t == nil {
+		return fmt.Errorf("%w: %s (see langdag inspect)", tool.ErrNotFound, name)
+	}
+	if err := tools.InitFromEnv(); err != nil {
+		return err
+	}
+	cfg.policies(tools, nil)
+
+	out, err := t.Call(ctx, *arguments)
+	if err != nil {
+		return err
+	}
+	result := tool.Result(t, out)
+	buf := new(bytes.Buffer)
+	if err := json.Indent(buf, result, "", "  "); err != nil {
+		buf.Reset()
+		buf.Write(result)
+	}
+	fmt.Printf("%s\n", buf)
+	return nil
+}
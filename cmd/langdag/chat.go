@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aluzzardi/langdag/agent"
+	"github.com/aluzzardi/langdag/prompts"
+	"github.com/aluzzardi/langdag/tool"
+	prompt "github.com/c-bata/go-prompt"
+	"github.com/openai/openai-go"
+)
+
+func chat(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	cfg.modelFlags(fs)
+	var (
+		maxContextTokens = fs.Int("max-context-tokens", 64000, "estimated token budget for the conversation history (0 to disable)")
+		historyStrategy  = fs.String("history", "truncate,drop", "comma separated list of history strategies: truncate, summarize, drop")
+		sessionFile      = fs.String("session", "", "JSONL transcript to resume and record the session to")
+		priceFile        = fs.String("prices", "", "JSON price table, in dollars per million tokens, used to compute costs")
+		maxTokens        = fs.Int64("max-tokens", 0, "abort once the session used this many tokens (0 for unlimited)")
+		maxCost          = fs.Float64("max-cost", 0, "abort once the session cost this many dollars (0 for unlimited)")
+		approveFrom      = fs.String("approve", "mutating", "ask before calling tools at least this dangerous: read-only, mutating or destructive")
+		maxFailures      = fs.Int("max-tool-failures", 3, "end a turn after this many consecutive failed tool calls (0 for no limit)")
+		maxSteps         = fs.Int("max-steps", agent.DefaultLimits.MaxSteps, "end a turn after this many model calls (0 for no limit)")
+		maxDuration      = fs.Duration("max-duration", 0, "end a turn after running this long (0 for no limit)")
+		maxRepeats       = fs.Int("max-repeats", agent.DefaultLimits.MaxRepeats, "number of identical tool calls allowed in a turn before nudging the model (0 for no limit)")
+		topTools         = fs.Int("top-tools", 0, "only send the tools most relevant to the conversation, and a search_tools tool to find the others (0 to send all tools)")
+		promptsFile      = fs.String("prompts", "", "JSON file of prompt templates, used with /prompt <name> key=value...")
+	)
+	mods, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+
+	threshold, err := tool.ParseSafety(*approveFrom)
+	if err != nil {
+		return err
+	}
+
+	tools, err := cfg.load(ctx, mods)
+	if err != nil {
+		return err
+	}
+	if err := tools.InitFromEnv(); err != nil {
+		return err
+	}
+	tools = append(tools, clock())
+	tools.RequireApproval(threshold, askApproval)
+	cfg.policies(tools, nil)
+	client := cfg.client(nil)
+
+	strategies, err := parseStrategies(*historyStrategy, client)
+	if err != nil {
+		return err
+	}
+	prices := agent.DefaultPrices
+	if *priceFile != "" {
+		prices, err = agent.LoadPriceTable(*priceFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	a := agent.New(client, tools)
+	a.Model = openai.ChatModel(cfg.model)
+	a.History = agent.NewHistory(*maxContextTokens, strategies...)
+	a.Meter = agent.NewMeter(prices, agent.Budget{MaxTokens: *maxTokens, MaxCost: *maxCost})
+	a.MaxToolFailures = *maxFailures
+	a.Limits = agent.Limits{MaxSteps: *maxSteps, MaxDuration: *maxDuration, MaxRepeats: *maxRepeats}
+	if *topTools > 0 {
+		a.Selector = agent.NewToolSelector(tools, *topTools)
+	}
+
+	if *sessionFile != "" {
+		transcript, entries, err := agent.OpenTranscript(*sessionFile)
+		if err != nil {
+			return err
+		}
+		defer transcript.Close()
+		a.Transcript = transcript
+
+		if msgs := agent.TranscriptMessages(entries); len(msgs) > 0 {
+			fmt.Fprintf(os.Stderr, "==> Resuming session with %d messages from %s\n\n", len(msgs), *sessionFile)
+			a.History.Append(msgs...)
+		}
+
+		names := make([]string, 0, len(tools))
+		for _, t := range tools {
+			names = append(names, t.Name())
+		}
+		if err := transcript.Record(&agent.Entry{
+			Session: &agent.SessionInfo{Model: a.Model, Tools: names},
+		}); err != nil {
+			return err
+		}
+	}
+
+	var templates prompts.Prompts
+	if *promptsFile != "" {
+		templates, err = prompts.Load(*promptsFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Resumed sessions already describe the modules.
+	if instructions := tools.Instructions(); instructions != "" && len(a.History.Messages()) == 0 {
+		if err := a.Append(agent.SystemMessage(instructions)); err != nil {
+			return err
+		}
+	}
+
+	inputHistory := []string{}
+	for {
+		question := prompt.Input("> ", noSuggestions, prompt.OptionHistory(inputHistory))
+		if question == "" {
+			continue
+		}
+		if question == "exit" {
+			break
+		}
+		if question == "/usage" {
+			fmt.Fprintf(os.Stderr, "%s\n", a.Meter.Report())
+			continue
+		}
+
+		inputHistory = append(inputHistory, question)
+		if question == "/prompt" || strings.HasPrefix(question, "/prompt ") {
+			question, err = expandPrompt(templates, strings.TrimPrefix(question, "/prompt"))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				continue
+			}
+			if question == "" {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "> %s\n", question)
+		}
+		fmt.Fprintf(os.Stderr, "\n")
+
+		// Errors end the turn, not the session.
+		reply, err := a.Run(ctx, question)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			continue
+		}
+
+		fmt.Printf("%s\n", reply.Content)
+	}
+
+	return nil
+}
+
+type clockArgs struct {
+	Timezone string `json:"timezone,omitempty" description:"IANA time zone, e.g. Europe/Paris. Defaults to the local time zone."`
+}
+
+// clock lets the model know the current time, which it otherwise can only
+// guess from its training data.
+func clock() tool.Tool {
+	t := tool.Func("clock_now", "Get the current date and time.", func(ctx context.Context, args clockArgs) (string, error) {
+		now := time.Now()
+		if args.Timezone != "" {
+			loc, err := time.LoadLocation(args.Timezone)
+			if err != nil {
+				return "", err
+			}
+			now = now.In(loc)
+		}
+		return now.Format(time.RFC1123Z), nil
+	})
+	t.SetSafety(tool.ReadOnly)
+	return t
+}
+
+// expandPrompt expands `/prompt <name> key=value...` into the prompt
+// template it names. Without a name, the templates are listed.
+func expandPrompt(templates prompts.Prompts, command string) (string, error) {
+	fields, err := splitFields(command)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 0 {
+		if len(templates) == 0 {
+			return "", fmt.Errorf("no prompts configured, see --prompts")
+		}
+		for _, p := range templates {
+			fmt.Fprintf(os.Stderr, "  %s\n    %s\n", p.Usage(), p.Description)
+		}
+		return "", nil
+	}
+
+	p := templates.Get(fields[0])
+	if p == nil {
+		return "", fmt.Errorf("unknown prompt %q", fields[0])
+	}
+	args := map[string]string{}
+	for _, field := range fields[1:] {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return "", fmt.Errorf("invalid argument %q (usage: %s)", field, p.Usage())
+		}
+		args[name] = value
+	}
+	return p.Expand(args)
+}
+
+// splitFields splits a command line on spaces, except within double quotes:
+// title="hello world".
+func splitFields(s string) ([]string, error) {
+	fields := []string{}
+	field := new(strings.Builder)
+	quoted, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case r == ' ' && !quoted:
+			if started {
+				fields = append(fields, field.String())
+				field.Reset()
+				started = false
+			}
+		default:
+			field.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if started {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// askApproval shows the call to the user and lets them run it, deny it or
+// edit its arguments.
+func askApproval(_ context.Context, req *tool.ApprovalRequest) (*tool.Approval, error) {
+	fmt.Fprintf(os.Stderr, "\n==> The model wants to call %s\n", req)
+	for {
+		answer := strings.ToLower(strings.TrimSpace(prompt.Input("Run it? [y]es/[n]o/[e]dit: ", noSuggestions)))
+		switch answer {
+		case "y", "yes":
+			return &tool.Approval{Approved: true}, nil
+		case "n", "no":
+			reason := prompt.Input("Reason (optional): ", noSuggestions)
+			if reason == "" {
+				reason = "the user refused"
+			}
+			return &tool.Approval{Reason: reason}, nil
+		case "e", "edit":
+			args := prompt.Input("Arguments: ", noSuggestions, prompt.OptionInitialBufferText(req.Arguments))
+			if !json.Valid([]byte(args)) {
+				fmt.Fprintf(os.Stderr, "invalid JSON, try again\n")
+				continue
+			}
+			return &tool.Approval{Approved: true, Arguments: args}, nil
+		}
+	}
+}
+
+func noSuggestions(prompt.Document) []prompt.Suggest {
+	return []prompt.Suggest{}
+}
+
+func parseStrategies(spec string, client *openai.Client) ([]agent.Strategy, error) {
+	strategies := []agent.Strategy{}
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "truncate":
+			strategies = append(strategies, agent.TruncateToolResults{MaxChars: 2000, KeepRecent: 2})
+		case "summarize":
+			strategies = append(strategies, agent.SummarizeTurns{
+				Summarizer: agent.ModelSummarizer(client, openai.ChatModelGPT4oMini),
+				KeepTurns:  2,
+			})
+		case "drop":
+			strategies = append(strategies, agent.DropOldestTurns{})
+		default:
+			return nil, fmt.Errorf("unknown history strategy %q", name)
+		}
+	}
+	return strategies, nil
+}
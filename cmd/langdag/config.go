@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"dagger.io/dagger"
+	"github.com/aluzzardi/langdag/retry"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// config is the configuration shared by the commands: how to connect to
+// dagger, load modules and call tools and models.
+type config struct {
+	safety            string
+	annotations       []tool.LoadOption
+	descriptionLength int
+	mcpServers        [][2]string
+	dryRun            bool
+	toolTimeout       time.Duration
+	toolRetries       int
+	retryTools        []string
+	log               string
+
+	model        string
+	modelTimeout time.Duration
+	modelRetries int
+
+	// descriptions describe the tools. MaxLength is set from the flags.
+	descriptions tool.Descriptions
+
+	// logOutput, if set, wraps the writer of the dagger log.
+	logOutput func(io.Writer) io.Writer
+
+	dag     *dagger.Client
+	closers []io.Closer
+}
+
+func newConfig() *config {
+	return &config{descriptions: tool.DefaultDescriptions}
+}
+
+// flags defines the flags loading modules and calling tools.
+func (c *config) flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.safety, "safety", "", "comma separated list of function=safety overrides, e.g. issue-comment=read-only")
+	fs.Func("annotations", "override the MCP annotations of a function, as function=title=...,idempotent=true,open-world=false (repeatable)", func(s string) error {
+		opt, err := parseAnnotations(s)
+		if err != nil {
+			return err
+		}
+		c.annotations = append(c.annotations, opt)
+		return nil
+	})
+	fs.IntVar(&c.descriptionLength, "description-length", tool.DefaultDescriptions.MaxLength, "number of characters tool descriptions are cut to (0 for no limit)")
+	fs.Func("mcp", "use the tools of an MCP server, as name=command or name=URL (repeatable)", func(s string) error {
+		name, target, ok := strings.Cut(s, "=")
+		if !ok || name == "" || target == "" {
+			return fmt.Errorf("expected name=command or name=URL")
+		}
+		c.mcpServers = append(c.mcpServers, [2]string{name, target})
+		return nil
+	})
+	fs.BoolVar(&c.dryRun, "dry-run", false, "print the query and dagger command of tool calls instead of executing them")
+	fs.DurationVar(&c.toolTimeout, "tool-timeout", 5*time.Minute, "timeout of a tool call attempt (0 for none)")
+	fs.IntVar(&c.toolRetries, "tool-retries", 2, "number of retries of read-only and idempotent tool calls failing with transient errors")
+	fs.Func("retry", "comma separated list of tools with side effects to retry anyway, e.g. github_issue-comment", func(s string) error {
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				c.retryTools = append(c.retryTools, name)
+			}
+		}
+		return nil
+	})
+	fs.StringVar(&c.log, "log", "-", "file the dagger log is written to: - for stderr, empty to discard it")
+}
+
+// modelFlags defines the flags calling models.
+func (c *config) modelFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.model, "model", string(openai.ChatModelGPT4o), "model to use")
+	fs.DurationVar(&c.modelTimeout, "model-timeout", 2*time.Minute, "timeout of a model call attempt (0 for none)")
+	fs.IntVar(&c.modelRetries, "model-retries", 4, "number of retries of model calls failing with transient errors or rate limits")
+}
+
+// connect connects to the dagger engine, once.
+func (c *config) connect(ctx context.Context) (*dagger.Client, error) {
+	if c.dag != nil {
+		return c.dag, nil
+	}
+	var w io.Writer = io.Discard
+	switch c.log {
+	case "":
+	case "-":
+		w = os.Stderr
+	default:
+		f, err := os.OpenFile(c.log, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open log: %w", err)
+		}
+		c.closers = append(c.closers, f)
+		w = f
+	}
+	if c.logOutput != nil {
+		w = c.logOutput(w)
+	}
+
+	dag, err := dagger.Connect(ctx, dagger.WithLogOutput(w))
+	if err != nil {
+		return nil, err
+	}
+	c.dag = dag
+	return dag, nil
+}
+
+// load loads the tools of modules and of the MCP servers. Module tools still
+// need to be initialized from the environment to be called.
+func (c *config) load(ctx context.Context, mods []string) (tool.Tools, error) {
+	dag, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := parseSafety(c.safety)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, c.annotations...)
+	descriptions := c.descriptions
+	descriptions.MaxLength = c.descriptionLength
+	opts = append(opts, tool.WithDescriptions(descriptions))
+
+	tools, err := tool.LoadAll(ctx, dag, mods, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, server := range c.mcpServers {
+		srv, err := tool.ConnectMCP(ctx, server[0], server[1])
+		if err != nil {
+			return nil, err
+		}
+		c.closers = append(c.closers, srv)
+		serverTools, err := srv.Tools(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, serverTools...)
+	}
+	return tools, nil
+}
+
+// policies applies the retry policy and dry run to tools. overrides are the
+// policies of specific tools. Only read-only and idempotent tools are
+// retried, and the tools listed with -retry.
+func (c *config) policies(tools tool.Tools, overrides map[string]retry.Policy) {
+	if c.dryRun {
+		tools.DryRun()
+	}
+	explicit := map[string]retry.Policy{}
+	for _, t := range tools {
+		p, ok := overrides[t.Name()]
+		if !ok {
+			p = c.toolPolicy()
+		}
+		switch {
+		case slices.Contains(c.retryTools, t.Name()):
+		case !tool.SafeToRetry(t):
+			p.MaxRetries = 0
+		}
+		explicit[t.Name()] = p
+	}
+	tools.Retry(c.toolPolicy(), explicit)
+}
+
+func (c *config) toolPolicy() retry.Policy {
+	p := retry.DefaultPolicy
+	p.Timeout = c.toolTimeout
+	p.MaxRetries = c.toolRetries
+	return p
+}
+
+// client returns a client of the models, retrying calls. transport, if not
+// nil, sends the requests.
+func (c *config) client(transport http.RoundTripper) *openai.Client {
+	p := retry.DefaultPolicy
+	p.Timeout = c.modelTimeout
+	p.MaxRetries = c.modelRetries
+	return openai.NewClient(
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(retry.HTTPClient(transport, p)),
+	)
+}
+
+// close closes the dagger session, the MCP servers and the log.
+func (c *config) close() {
+	if c.dag != nil {
+		c.dag.Close()
+	}
+	for _, closer := range c.closers {
+		closer.Close()
+	}
+}
+
+func parseSafety(spec string) ([]tool.LoadOption, error) {
+	opts := []tool.LoadOption{}
+	for _, override := range strings.Split(spec, ",") {
+		if strings.TrimSpace(override) == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid safety override %q (expected function=safety)", override)
+		}
+		safety, err := tool.ParseSafety(strings.TrimSpace(level))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, tool.WithSafety(strings.TrimSpace(name), safety))
+	}
+	return opts, nil
+}
+
+func parseAnnotations(spec string) (tool.LoadOption, error) {
+	name, fields, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("invalid annotations override %q (expected function=annotations)", spec)
+	}
+	annotations, err := tool.ParseAnnotations(fields)
+	if err != nil {
+		return nil, err
+	}
+	return tool.WithAnnotations(strings.TrimSpace(name), annotations), nil
+}
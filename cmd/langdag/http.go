@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// authenticate requires requests to carry the bearer token, if any.
+func authenticate(next http.Handler, token string) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="langdag"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// warnUnauthenticated warns about serving without a token on an address
+// reachable from other hosts.
+func warnUnauthenticated(addr, token string) {
+	if token == "" && !isLoopback(addr) {
+		fmt.Fprintf(os.Stderr, "warning: serving on %s without authentication, set --token\n", addr)
+	}
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/aluzzardi/langdag/tool"
+)
+
+// toolInfo describes a tool in the output of inspect.
+type toolInfo struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Safety      tool.Safety        `json:"safety"`
+	Returns     string             `json:"returns,omitempty"`
+	Schema      *jsonschema.Schema `json:"schema"`
+}
+
+func inspect(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	asJSON := fs.Bool("json", false, "print the modules and tools as JSON")
+	mods, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+
+	tools, err := cfg.load(ctx, mods)
+	if err != nil {
+		return err
+	}
+	modules := tools.Modules()
+	infos := map[string]*toolInfo{}
+	for _, t := range tools {
+		info := &toolInfo{
+			Name:        t.Name(),
+			Description: t.Description(),
+			Safety:      tool.SafetyOf(t),
+			Schema:      t.Schema(),
+		}
+		if mt, ok := t.(*tool.ModuleTool); ok {
+			info.Returns = mt.Returns()
+		}
+		infos[t.Name()] = info
+	}
+
+	if *asJSON {
+		list := make([]*toolInfo, 0, len(tools))
+		for _, t := range tools {
+			list = append(list, infos[t.Name()])
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{"modules": modules, "tools": list})
+	}
+
+	inModule := map[string]bool{}
+	for _, mod := range modules {
+		fmt.Printf("%s", mod.Name)
+		if mod.Ref != "" {
+			fmt.Printf(" (%s)", mod.Ref)
+		}
+		if mod.Description != "" {
+			fmt.Printf(": %s", mod.Description)
+		}
+		fmt.Printf("\n")
+		if len(mod.Constructor) > 0 {
+			fmt.Printf("  constructor:\n")
+			for _, arg := range mod.Constructor {
+				status := "set"
+				if !arg.Set {
+					status = "NOT SET"
+				}
+				fmt.Printf("    %s %s from $%s (%s)\n", arg.Name, arg.Type, arg.Env, status)
+			}
+		}
+		fmt.Printf("  tools:\n")
+		for _, name := range mod.Tools {
+			printTool(infos[name])
+			inModule[name] = true
+		}
+		if len(mod.Skipped) > 0 {
+			fmt.Printf("  skipped: %s\n", strings.Join(mod.Skipped, ", "))
+		}
+	}
+
+	others := []string{}
+	for _, t := range tools {
+		if !inModule[t.Name()] {
+			others = append(others, t.Name())
+		}
+	}
+	if len(others) > 0 {
+		fmt.Printf("other tools:\n")
+		for _, name := range others {
+			printTool(infos[name])
+		}
+	}
+	return nil
+}
+
+func printTool(t *toolInfo) {
+	fmt.Printf("    %s [%s]", t.Name, t.Safety)
+	if t.Returns != "" {
+		fmt.Printf(" -> %s", t.Returns)
+	}
+	fmt.Printf("\n")
+	if line, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n"); line != "" {
+		fmt.Printf("      %s\n", line)
+	}
+	names := make([]string, 0, len(t.Schema.Properties))
+	for name := range t.Schema.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		arg := t.Schema.Properties[name]
+		required := ""
+		if slices.Contains(t.Schema.Required, name) {
+			required = ", required"
+		}
+		fmt.Printf("      --%s (%s%s)", name, typeName(arg), required)
+		if arg.Description != "" {
+			line, _, _ := strings.Cut(arg.Description, "\n")
+			fmt.Printf(": %s", line)
+		}
+		fmt.Printf("\n")
+	}
+}
+
+// typeName names the type of a schema: string, []integer, a|b.
+func typeName(s *jsonschema.Schema) string {
+	switch {
+	case len(s.Enum) > 0:
+		values := make([]string, 0, len(s.Enum))
+		for _, v := range s.Enum {
+			values = append(values, fmt.Sprint(v))
+		}
+		return strings.Join(values, "|")
+	case s.Items != nil:
+		return "[]" + typeName(s.Items)
+	case s.Type == "":
+		return "any"
+	}
+	return s.Type
+}
@@ -0,0 +1,155 @@
+// Command langdag uses dagger modules as LLM tools: chat with them, give them
+// to an agent reacting to webhooks, serve them over MCP, REST or a chat
+// completions proxy, or call and inspect them directly.
+//
+//	langdag <command> [flags] [arguments]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// command is a subcommand of langdag.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	// run runs the command with its arguments, defining its flags on fs.
+	run func(ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []*command{
+	{
+		name:    "chat",
+		usage:   "[flags] <modules...>",
+		summary: "Chat with modules",
+		run:     chat,
+	},
+	{
+		name:    "serve",
+		usage:   "[flags] <mission> <modules...>",
+		summary: "Use modules to accomplish a mission, reacting to GitHub webhooks",
+		run:     serve,
+	},
+	{
+		name:    "mcp",
+		usage:   "[flags] <modules...>",
+		summary: "Serve modules over MCP",
+		run:     mcp,
+	},
+	{
+		name:    "rest",
+		usage:   "[flags] <modules...>",
+		summary: "Serve modules as a REST API described by an OpenAPI document",
+		run:     rest,
+	},
+	{
+		name:    "proxy",
+		usage:   "[flags] <modules...>",
+		summary: "Give modules to any OpenAI client through a chat completions proxy",
+		run:     chatProxy,
+	},
+	{
+		name:    "call",
+		usage:   "[flags] <tool> <modules...>",
+		summary: "Call a tool directly, without a model",
+		run:     call,
+	},
+	{
+		name:    "inspect",
+		usage:   "[flags] <modules...>",
+		summary: "List the tools of modules, their arguments and what modules need",
+		run:     inspect,
+	},
+	{
+		name:    "schema",
+		usage:   "[flags] <modules...>",
+		summary: "Print the schemas of the tools of modules for a provider",
+		run:     schema,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		usage()
+		os.Exit(2)
+	}
+	cmd := lookup(os.Args[1])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cmd.run(ctx, cmd.flagSet(), os.Args[2:])
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: langdag <command> [flags] [arguments]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun langdag <command> -h for the flags of a command.\n")
+}
+
+func lookup(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: langdag %s %s\n\n%s.\n\nflags:\n", c.name, c.usage, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags of a command, which may come before, between or
+// after its arguments, and checks it got at least min arguments.
+func parse(fs *flag.FlagSet, args []string, min int) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		// Everything after "--" is an argument.
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	if len(positional) < min {
+		fs.Usage()
+		return nil, flag.ErrHelp
+	}
+	return positional, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aluzzardi/langdag/prompts"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func mcp(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	var (
+		listen      = fs.String("listen", "", "serve over HTTP on this address, e.g. localhost:8080, rather than over stdio")
+		promptsFile = fs.String("prompts", "", "JSON file of prompt templates to serve")
+		token       = fs.String("token", os.Getenv("LANGDAG_TOKEN"), "bearer token HTTP clients must send (defaults to $LANGDAG_TOKEN)")
+	)
+	mods, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+
+	// Forward the dagger log to clients as progress of their calls.
+	cfg.logOutput = tool.MCPLogOutput
+	// MCP clients have no system prompt for the modules: describe them in
+	// every tool.
+	cfg.descriptions.Module = true
+
+	tools, err := cfg.load(ctx, mods)
+	if err != nil {
+		return err
+	}
+	if err := tools.InitFromEnv(); err != nil {
+		return err
+	}
+	cfg.policies(tools, nil)
+
+	s := server.NewMCPServer(
+		"langdag",
+		"1.0.0",
+		server.WithResourceCapabilities(false, false),
+		server.WithPromptCapabilities(false),
+		server.WithHooks(tool.MCPHooks()),
+	)
+	s.AddNotificationHandler("notifications/cancelled", tool.MCPCancelHandler())
+	for _, t := range tools {
+		s.AddTool(tool.ToMCP(t), tool.MCPHandler(t))
+	}
+	for _, r := range tools.Resources() {
+		s.AddResource(tool.ToMCPResource(r), tool.MCPResourceHandler(r))
+	}
+	// Files and directories returned by tools
+	s.AddResourceTemplate(tool.MCPResultTemplate(), tool.MCPResultHandler())
+	if *promptsFile != "" {
+		templates, err := prompts.Load(*promptsFile)
+		if err != nil {
+			return err
+		}
+		for _, p := range templates {
+			s.AddPrompt(prompts.ToMCP(p), prompts.MCPHandler(p))
+		}
+	}
+
+	if *listen == "" {
+		// Start the stdio server
+		return server.ServeStdio(s)
+	}
+	return serveHTTP(s, *listen, *token)
+}
+
+// serveHTTP serves the streamable HTTP transport on /mcp and, for older
+// clients, the SSE transport on /sse. All clients share the dagger session
+// and the loaded modules.
+func serveHTTP(s *server.MCPServer, addr, token string) error {
+	sse := server.NewSSEServer(s)
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", server.NewStreamableHTTPServer(s))
+	mux.Handle("/sse", sse)
+	mux.Handle("/message", sse)
+
+	warnUnauthenticated(addr, token)
+	fmt.Fprintf(os.Stderr, "==> serving MCP on http://%s/mcp (SSE on /sse)\n", addr)
+	return http.ListenAndServe(addr, authenticate(mux, token))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aluzzardi/langdag/proxy"
+	"github.com/openai/openai-go"
+)
+
+func chatProxy(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	cfg.modelFlags(fs)
+	// Clients pick the model, unless one is forced.
+	model := fs.Lookup("model")
+	model.Usage = "model to use whatever clients ask for (defaults to the model of requests)"
+	model.DefValue = ""
+	cfg.model = ""
+	var (
+		listen    = fs.String("listen", "localhost:8080", "address to serve the API on")
+		token     = fs.String("token", os.Getenv("LANGDAG_TOKEN"), "bearer token clients must send (defaults to $LANGDAG_TOKEN)")
+		deny      = fs.String("deny", "destructive", "deny calls to tools at least this dangerous: read-only, mutating or destructive (empty to allow all)")
+		maxTokens = fs.Int64("max-tokens", 0, "abort requests using this many tokens, tool calls included (0 for unlimited)")
+	)
+	mods, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+
+	tools, err := cfg.load(ctx, mods)
+	if err != nil {
+		return err
+	}
+	if err := tools.InitFromEnv(); err != nil {
+		return err
+	}
+	if err := denyFrom(tools, *deny); err != nil {
+		return err
+	}
+	cfg.policies(tools, nil)
+
+	s := proxy.New(cfg.client(nil), tools)
+	s.Model = openai.ChatModel(cfg.model)
+	s.Token = *token
+	s.MaxTokens = *maxTokens
+	warnUnauthenticated(*listen, *token)
+
+	fmt.Fprintf(os.Stderr, "==> serving chat completions on http://%s/v1 with %d tools\n", *listen, len(tools))
+	return http.ListenAndServe(*listen, s)
+}
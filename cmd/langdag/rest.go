@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/aluzzardi/langdag/gateway"
+	"github.com/aluzzardi/langdag/tool"
+)
+
+func rest(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	var (
+		listen  = fs.String("listen", "localhost:8080", "address to serve the API on")
+		token   = fs.String("token", os.Getenv("LANGDAG_TOKEN"), "bearer token clients must send (defaults to $LANGDAG_TOKEN)")
+		deny    = fs.String("deny", "destructive", "deny calls to tools at least this dangerous: read-only, mutating or destructive (empty to allow all)")
+		proxies = fs.String("trusted-proxies", "", "comma separated list of addresses or CIDR ranges of the reverse proxies whose X-Forwarded headers are honoured")
+	)
+	mods, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	trusted, err := parsePrefixes(*proxies)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+
+	tools, err := cfg.load(ctx, mods)
+	if err != nil {
+		return err
+	}
+	if err := tools.InitFromEnv(); err != nil {
+		return err
+	}
+	if err := denyFrom(tools, *deny); err != nil {
+		return err
+	}
+	cfg.policies(tools, nil)
+
+	g := gateway.New(tools)
+	g.Token = *token
+	g.TrustedProxies = trusted
+	warnUnauthenticated(*listen, *token)
+
+	fmt.Fprintf(os.Stderr, "==> serving %d tools on http://%s/tools (OpenAPI on /openapi.json)\n", len(tools), *listen)
+	return http.ListenAndServe(*listen, g)
+}
+
+// denyFrom denies calls to tools at least as dangerous as safety, if set:
+// nobody is there to approve them.
+func denyFrom(tools tool.Tools, safety string) error {
+	if safety == "" {
+		return nil
+	}
+	threshold, err := tool.ParseSafety(safety)
+	if err != nil {
+		return err
+	}
+	tools.RequireApproval(threshold, tool.DenyAll)
+	return nil
+}
+
+// parsePrefixes parses a comma separated list of addresses and CIDR ranges.
+func parsePrefixes(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range strings.Split(spec, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
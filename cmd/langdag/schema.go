@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/aluzzardi/langdag/tool"
+)
+
+// providers are the formats of the schema command.
+var providers = []string{"openai", "anthropic", "gemini", "mcp", "openapi"}
+
+func schema(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	provider := fs.String("provider", "openai", "format of the schemas: "+strings.Join(providers, ", "))
+	mods, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	defer cfg.close()
+
+	if *provider == "mcp" {
+		// As served by the mcp command.
+		cfg.descriptions.Module = true
+	}
+	tools, err := cfg.load(ctx, mods)
+	if err != nil {
+		return err
+	}
+
+	var out any
+	switch *provider {
+	case "openai":
+		out = tools.Functions()
+	case "anthropic":
+		defs := make([]map[string]any, 0, len(tools))
+		for _, t := range tools {
+			defs = append(defs, map[string]any{
+				"name":         t.Name(),
+				"description":  t.Description(),
+				"input_schema": t.Schema().Map(),
+			})
+		}
+		out = defs
+	case "gemini":
+		decls := make([]map[string]any, 0, len(tools))
+		for _, t := range tools {
+			decl := map[string]any{
+				"name":        t.Name(),
+				"description": t.Description(),
+			}
+			// Gemini rejects objects without properties.
+			if s := t.Schema(); len(s.Properties) > 0 {
+				decl["parameters"] = geminiSchema(s)
+			}
+			decls = append(decls, decl)
+		}
+		out = map[string]any{"functionDeclarations": decls}
+	case "mcp":
+		defs := make([]any, 0, len(tools))
+		for _, t := range tools {
+			defs = append(defs, tool.ToMCP(t))
+		}
+		out = map[string]any{"tools": defs}
+	case "openapi":
+		out = tools.OpenAPI("langdag", "1.0.0", "")
+	default:
+		return fmt.Errorf("unknown provider %q, expected one of %s", *provider, strings.Join(providers, ", "))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// geminiSchema converts a schema to the OpenAPI subset Gemini supports,
+// whose types are upper case.
+func geminiSchema(s *jsonschema.Schema) map[string]any {
+	out := map[string]any{}
+	if s.Type != "" {
+		out["type"] = strings.ToUpper(s.Type)
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if len(s.Enum) > 0 {
+		out["format"] = "enum"
+		out["enum"] = s.Enum
+	}
+	if s.Items != nil {
+		out["items"] = geminiSchema(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		properties := map[string]any{}
+		for name, prop := range s.Properties {
+			properties[name] = geminiSchema(prop)
+		}
+		out["properties"] = properties
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	return out
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aluzzardi/langdag/agent"
+	"github.com/aluzzardi/langdag/cassette"
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/aluzzardi/langdag/retry"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+)
+
+func serve(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	cfg := newConfig()
+	cfg.flags(fs)
+	cfg.modelFlags(fs)
+	var (
+		listen         = fs.String("listen", ":9000", "address to receive webhooks on")
+		transcriptsDir = fs.String("transcripts", "transcripts", "directory where a transcript is kept for every webhook delivery (empty to disable)")
+		recordFile     = fs.String("record", "", "record model and tool interactions to a cassette file")
+		replayFile     = fs.String("replay", "", "replay model and tool interactions from a cassette file, without network or engine access")
+		priceFile      = fs.String("prices", "", "JSON price table, in dollars per million tokens, used to compute costs")
+		maxTokens      = fs.Int64("max-tokens", 0, "abort a webhook once it used this many tokens (0 for unlimited)")
+		maxCost        = fs.Float64("max-cost", 0, "abort a webhook once it cost this many dollars (0 for unlimited)")
+		resultSchema   = fs.String("result-schema", "", "JSON schema file the final answer to every webhook must match")
+		approval       = fs.String("approval", "allow", "policy for calls needing approval: allow, deny, or a URL the calls are POSTed to for a decision")
+		approveFrom    = fs.String("approve", "mutating", "apply the approval policy to tools at least this dangerous: read-only, mutating or destructive")
+		toolTimeouts   = fs.String("tool-timeouts", "", "comma separated list of tool=timeout overrides, e.g. trufflehog_git=20m")
+		maxFailures    = fs.Int("max-tool-failures", 3, "abort a webhook after this many consecutive failed tool calls (0 for no limit)")
+		maxSteps       = fs.Int("max-steps", agent.DefaultLimits.MaxSteps, "abort a webhook after this many model calls (0 for no limit)")
+		maxDuration    = fs.Duration("max-duration", 10*time.Minute, "abort a webhook after running this long (0 for no limit)")
+		maxRepeats     = fs.Int("max-repeats", agent.DefaultLimits.MaxRepeats, "number of identical tool calls allowed per webhook before nudging the model (0 for no limit)")
+		topTools       = fs.Int("top-tools", 0, "only send the tools most relevant to the conversation, and a search_tools tool to find the others (0 to send all tools)")
+		maxContext     = fs.Int("max-context-tokens", 64000, "estimated token budget for the conversation history of a webhook (0 to disable)")
+	)
+	args, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	mission, mods := args[0], args[1:]
+	// Dry runs replace the transports of the tools, cassettes included.
+	if cfg.dryRun && (*recordFile != "" || *replayFile != "") {
+		return fmt.Errorf("-dry-run can't be combined with -record or -replay")
+	}
+	defer cfg.close()
+
+	load := func() (tool.Tools, error) {
+		tools, err := cfg.load(ctx, mods)
+		if err != nil {
+			return nil, err
+		}
+		if err := tools.InitFromEnv(); err != nil {
+			return nil, err
+		}
+		return tools, nil
+	}
+
+	var tape *cassette.Cassette
+	switch {
+	case *recordFile != "":
+		tape, err = cassette.Open(*recordFile, cassette.ModeRecord)
+	case *replayFile != "":
+		tape, err = cassette.Open(*replayFile, cassette.ModeReplay)
+	}
+	if err != nil {
+		return err
+	}
+
+	approver, err := approvalPolicy(*approval)
+	if err != nil {
+		return err
+	}
+	threshold, err := tool.ParseSafety(*approveFrom)
+	if err != nil {
+		return err
+	}
+	toolOverrides, err := parseTimeouts(*toolTimeouts, cfg.toolPolicy())
+	if err != nil {
+		return err
+	}
+
+	var (
+		tools     tool.Tools
+		modelHTTP http.RoundTripper
+	)
+	if tape != nil {
+		tools, err = tape.LoadTools(load)
+		modelHTTP = tape.HTTPClient().Transport
+	} else {
+		tools, err = load()
+	}
+	if err != nil {
+		return err
+	}
+	tools.RequireApproval(threshold, approver)
+	cfg.policies(tools, toolOverrides)
+
+	client := cfg.client(modelHTTP)
+
+	var result *agent.ResultSchema
+	if *resultSchema != "" {
+		data, err := os.ReadFile(*resultSchema)
+		if err != nil {
+			return err
+		}
+		schema, err := jsonschema.Parse(data)
+		if err != nil {
+			return err
+		}
+		result = &agent.ResultSchema{Name: "result", Schema: schema}
+	}
+
+	prices := agent.DefaultPrices
+	if *priceFile != "" {
+		prices, err = agent.LoadPriceTable(*priceFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Cassettes are sequential: process one webhook at a time.
+	var tapeMu sync.Mutex
+
+	if *transcriptsDir != "" {
+		if err := os.MkdirAll(*transcriptsDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	toolNames := make([]string, 0, len(tools))
+	for _, t := range tools {
+		toolNames = append(toolNames, t.Name())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if tape != nil {
+			tapeMu.Lock()
+			defer tapeMu.Unlock()
+			defer func() {
+				if err := tape.Save(); err != nil {
+					fmt.Fprintf(os.Stderr, "error: cassette: %v\n", err)
+				}
+			}()
+		}
+
+		event := r.Header.Get("X-GitHub-Event")
+		delivery := r.Header.Get("X-GitHub-Delivery")
+		if delivery == "" {
+			delivery = time.Now().UTC().Format("20060102T150405.000000000")
+		}
+
+		a := agent.New(client, tools)
+		a.Model = openai.ChatModel(cfg.model)
+		// The mission is a system message so it's never compacted away.
+		a.History = agent.NewHistory(*maxContext,
+			agent.TruncateToolResults{MaxChars: 2000, KeepRecent: 2},
+			agent.DropOldestTurns{},
+		)
+		a.Meter = agent.NewMeter(prices, agent.Budget{MaxTokens: *maxTokens, MaxCost: *maxCost})
+		a.MaxToolFailures = *maxFailures
+		a.Limits = agent.Limits{MaxSteps: *maxSteps, MaxDuration: *maxDuration, MaxRepeats: *maxRepeats}
+		if *topTools > 0 {
+			a.Selector = agent.NewToolSelector(tools, *topTools)
+		}
+		defer func() {
+			fmt.Fprintf(os.Stderr, "==> usage for %s %s:\n%s", event, delivery, a.Meter.Report())
+		}()
+
+		if *transcriptsDir != "" {
+			t, _, err := agent.OpenTranscript(filepath.Join(*transcriptsDir, filepath.Base(delivery)+".jsonl"))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				http.Error(w, "fail", http.StatusInternalServerError)
+				return
+			}
+			defer t.Close()
+			a.Transcript = t
+		}
+		if err := a.Transcript.Record(&agent.Entry{
+			Session: &agent.SessionInfo{ID: delivery, Model: a.Model, Tools: toolNames},
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			http.Error(w, "fail", http.StatusInternalServerError)
+			return
+		}
+
+		system := []*agent.Message{
+			agent.SystemMessage("You are an agent that reacts to GitHub webhooks. Your goal is to comply to the user provided mission and then process incoming webhooks and take actions according to the request."),
+			agent.SystemMessage("Mission: " + mission),
+		}
+		if instructions := tools.Instructions(); instructions != "" {
+			system = append(system, agent.SystemMessage(instructions))
+		}
+		if err := a.Append(system...); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			http.Error(w, "fail", http.StatusInternalServerError)
+			return
+		}
+
+		payload, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "==> processing incoming %s\n", event)
+
+		input := fmt.Sprintf("incoming webhook of type %s: %s", event, payload)
+		if result != nil {
+			data, err := a.RunResult(ctx, input, result)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				http.Error(w, "fail", http.StatusInternalServerError)
+				return
+			}
+			fmt.Printf("%s\n", data)
+			return
+		}
+
+		reply, err := a.Run(ctx, input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			http.Error(w, "fail", http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Printf("=> %s\n", reply.Content)
+	})
+
+	fmt.Fprintf(os.Stderr, "\n\n\n==> Agent Started.\n")
+	fmt.Fprintf(os.Stderr, "My mission is to %s\n", mission)
+
+	fmt.Fprintf(os.Stderr, "==> Listening on %s\n", *listen)
+
+	return http.ListenAndServe(*listen, mux)
+}
+
+func parseTimeouts(spec string, base retry.Policy) (map[string]retry.Policy, error) {
+	overrides := map[string]retry.Policy{}
+	for _, override := range strings.Split(spec, ",") {
+		if strings.TrimSpace(override) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid timeout override %q (expected tool=timeout)", override)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout override %q: %w", override, err)
+		}
+		p := base
+		p.Timeout = timeout
+		overrides[strings.TrimSpace(name)] = p
+	}
+	return overrides, nil
+}
+
+// approvalPolicy returns the approver for a headless policy. URLs receive the
+// tool.ApprovalRequest as JSON and must reply with a tool.Approval.
+func approvalPolicy(policy string) (tool.Approver, error) {
+	if !strings.HasPrefix(policy, "http://") && !strings.HasPrefix(policy, "https://") {
+		return tool.ParsePolicy(policy)
+	}
+
+	return func(ctx context.Context, req *tool.ApprovalRequest) (*tool.Approval, error) {
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, policy, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("approval callback returned %s", resp.Status)
+		}
+
+		approval := &tool.Approval{}
+		if err := json.NewDecoder(resp.Body).Decode(approval); err != nil {
+			return nil, fmt.Errorf("invalid approval callback response: %w", err)
+		}
+		return approval, nil
+	}, nil
+}
@@ -1,4 +1,4 @@
 #!/bin/bash
 
-go run . "reply to incoming issues and comments. make it funny. do not respond to comments prefixed with 🤖 (and make sure to use that prefix for your own messages)" \
+go run ../../cmd/langdag serve "reply to incoming issues and comments. make it funny. do not respond to comments prefixed with 🤖 (and make sure to use that prefix for your own messages)" \
 	../../modules/github
@@ -1,5 +1,5 @@
 #!/bin/bash
 
-go run . \
+go run ../../cmd/langdag serve \
 	"Whenever a new pull request is open, scan it for leaked secrets. If you find any, add a comment to the PR with a report." \
 	../../modules/github ../../modules/trufflehog
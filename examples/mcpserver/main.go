@@ -0,0 +1,135 @@
+// Command mcpserver is a small MCP server over stdio, keeping notes in
+// memory. It stands in for third-party MCP servers:
+//
+//	langdag chat --mcp notes="go run ./examples/mcpserver" <modules...>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type notes struct {
+	mu    sync.Mutex
+	next  int
+	notes map[int]string
+}
+
+func main() {
+	n := &notes{next: 1, notes: map[int]string{}}
+
+	s := server.NewMCPServer("notes", "1.0.0")
+	s.AddTool(mcp.NewTool("add",
+		mcp.WithDescription("Write down a note."),
+		mcp.WithString("text", mcp.Required(), mcp.Description("Text of the note")),
+		mcp.WithDestructiveHintAnnotation(false),
+	), n.add)
+	s.AddTool(mcp.NewTool("list",
+		mcp.WithDescription("List the notes, with their IDs."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), n.list)
+	s.AddTool(mcp.NewTool("delete",
+		mcp.WithDescription("Delete a note."),
+		mcp.WithNumber("id", mcp.Required(), mcp.Description("ID of the note")),
+		mcp.WithDestructiveHintAnnotation(true),
+	), n.delete)
+
+	// Third-party servers use all of JSON Schema.
+	s.AddTool(mcp.NewToolWithRawSchema("search", "Search the notes.", json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {"type": ["string", "null"], "pattern": "^[\\w ]*$", "description": "Words to look for"},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"limit": {"$ref": "#/$defs/limit"},
+			"order": {"oneOf": [{"const": "asc"}, {"const": "desc"}]}
+		},
+		"$defs": {"limit": {"type": "integer", "minimum": 1}}
+	}`)), n.search)
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (n *notes) add(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	text, err := request.RequireString("text")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	id := n.next
+	n.next++
+	n.notes[id] = text
+	return mcp.NewToolResultText(fmt.Sprintf("added note %d", id)), nil
+}
+
+func (n *notes) list(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.notes) == 0 {
+		return mcp.NewToolResultText("no notes"), nil
+	}
+	ids := make([]int, 0, len(n.notes))
+	for id := range n.notes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	lines := make([]string, 0, len(ids))
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("%d: %s", id, n.notes[id]))
+	}
+	return mcp.NewToolResultText(strings.Join(lines, "\n")), nil
+}
+
+func (n *notes) search(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query := request.GetString("query", "")
+	limit := request.GetInt("limit", 0)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ids := make([]int, 0, len(n.notes))
+	for id, text := range n.notes {
+		if strings.Contains(text, query) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	if request.GetString("order", "asc") == "desc" {
+		slices.Reverse(ids)
+	}
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	lines := make([]string, 0, len(ids))
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("%d: %s", id, n.notes[id]))
+	}
+	return mcp.NewToolResultText(strings.Join(lines, "\n")), nil
+}
+
+func (n *notes) delete(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := request.RequireInt("id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.notes[id]; !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("no note %d", id)), nil
+	}
+	delete(n.notes, id)
+	return mcp.NewToolResultText(fmt.Sprintf("deleted note %d", id)), nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"dagger.io/dagger"
+	"github.com/aluzzardi/langdag/agent"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+)
+
+type Report struct {
+	Repository string    `json:"repository" description:"URL of the scanned repository"`
+	Findings   []Finding `json:"findings" description:"Leaked secrets, empty if none were found"`
+}
+
+type Finding struct {
+	Detector string `json:"detector" description:"Kind of secret, e.g. AWS or Github"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Commit   string `json:"commit"`
+	Verified bool   `json:"verified" description:"Whether the secret was confirmed to be live"`
+}
+
+func main() {
+	repo := "https://github.com/trufflesecurity/test_keys"
+	if len(os.Args) > 1 {
+		repo = os.Args[1]
+	}
+	if err := scan(context.Background(), repo); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func scan(ctx context.Context, repo string) error {
+	dag, err := dagger.Connect(ctx, dagger.WithLogOutput(os.Stderr))
+	if err != nil {
+		return err
+	}
+	defer dag.Close()
+
+	tools, err := tool.Load(ctx, dag, "github.com/aluzzardi/langdag/modules/trufflehog", nil)
+	if err != nil {
+		return err
+	}
+
+	client := openai.NewClient()
+	a := agent.New(client, tools)
+	if err := a.Append(agent.SystemMessage(tools.Instructions())); err != nil {
+		return err
+	}
+
+	report, err := agent.RunAs[Report](ctx, a, fmt.Sprintf("Scan %s for leaked secrets and report your findings.", repo))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "==> %d findings in %s\n", len(report.Findings), report.Repository)
+	return json.NewEncoder(os.Stdout).Encode(report)
+}
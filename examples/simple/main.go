@@ -37,6 +37,7 @@ func main() {
 
 	params := openai.ChatCompletionNewParams{
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(tools.Instructions()),
 			openai.UserMessage(question),
 		}),
 		Tools: openai.F(tools.Functions()),
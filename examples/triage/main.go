@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"dagger.io/dagger"
+	"github.com/aluzzardi/langdag/agent"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <request>\n", os.Args[0])
+		os.Exit(1)
+	}
+	if err := triage(context.Background(), strings.Join(os.Args[1:], " ")); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func triage(ctx context.Context, request string) error {
+	dag, err := dagger.Connect(ctx, dagger.WithLogOutput(os.Stderr))
+	if err != nil {
+		return err
+	}
+	defer dag.Close()
+
+	githubTools, err := tool.Load(ctx, dag, "github.com/aluzzardi/langdag/modules/github", nil)
+	if err != nil {
+		return err
+	}
+	if err := githubTools.InitFromEnv(); err != nil {
+		return err
+	}
+	scanTools, err := tool.Load(ctx, dag, "github.com/aluzzardi/langdag/modules/trufflehog", nil)
+	if err != nil {
+		return err
+	}
+
+	client := openai.NewClient()
+
+	// The reviewer only sees the scanner, the triage agent only sees GitHub
+	// and the reviewer.
+	reviewer := agent.New(client, scanTools)
+	reviewer.Model = openai.ChatModelGPT4oMini
+	reviewer.Meter = agent.NewMeter(nil, agent.Budget{MaxCost: 0.10})
+	if err := reviewer.Append(agent.SystemMessage("You are a security reviewer. Scan the repositories you are given for leaked secrets and report what you found, with file, line and commit.\n\n" + scanTools.Instructions())); err != nil {
+		return err
+	}
+
+	tools := append(githubTools, reviewer.AsTool("security_reviewer", "Delegate a security review of a repository to an expert."))
+
+	a := agent.New(client, tools)
+	a.Meter = agent.NewMeter(nil, agent.Budget{})
+	a.Transcript = agent.NewTranscript(os.Stderr)
+	if err := a.Append(agent.SystemMessage("You triage GitHub issues and pull requests. Delegate security concerns to the security reviewer.\n\n" + githubTools.Instructions())); err != nil {
+		return err
+	}
+
+	reply, err := a.Run(ctx, request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "==> usage:\n%s", a.Meter.Report())
+	fmt.Printf("%s\n", reply.Content)
+	return nil
+}
@@ -0,0 +1,213 @@
+// Package gateway serves tools as a REST API, for services that want the
+// functions agents use without going through a model.
+//
+// Each tool is mounted at POST /tools/{name}, taking its arguments as a JSON
+// body. GET /tools lists the tools and GET /openapi.json describes the API,
+// e.g. to import it as the actions of a GPT.
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/aluzzardi/langdag/tool"
+)
+
+// MaxBodySize is the size of the largest request body accepted.
+const MaxBodySize = 1 << 20
+
+// Gateway is an http.Handler calling tools.
+type Gateway struct {
+	Tools tool.Tools
+
+	// Title and Version describe the API in the OpenAPI document.
+	Title   string
+	Version string
+
+	// Token, if set, is the bearer token clients must send.
+	Token string
+
+	// TrustedProxies are the addresses of the reverse proxies whose
+	// X-Forwarded-Proto and X-Forwarded-Host headers are honoured when
+	// building the URL of the API. The headers of other clients are ignored.
+	TrustedProxies []netip.Prefix
+
+	mux *http.ServeMux
+}
+
+// New returns a gateway serving tools.
+func New(tools tool.Tools) *Gateway {
+	g := &Gateway{
+		Tools:   tools,
+		Title:   "langdag",
+		Version: "1.0.0",
+		mux:     http.NewServeMux(),
+	}
+	g.mux.HandleFunc("GET /tools", g.list)
+	g.mux.HandleFunc("POST /tools/{name}", g.call)
+	g.mux.HandleFunc("GET /openapi.json", g.openAPI)
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g.Token != "" {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(g.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="langdag"`)
+			writeError(w, http.StatusUnauthorized, &Error{Error: "unauthorized"})
+			return
+		}
+	}
+	g.mux.ServeHTTP(w, r)
+}
+
+// Entry describes a tool in the catalog served on GET /tools.
+type Entry struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Safety      tool.Safety        `json:"safety"`
+	Annotations tool.Annotations   `json:"annotations"`
+	Schema      *jsonschema.Schema `json:"schema"`
+}
+
+func (g *Gateway) list(w http.ResponseWriter, r *http.Request) {
+	entries := make([]*Entry, 0, len(g.Tools))
+	for _, t := range g.Tools {
+		entries = append(entries, &Entry{
+			Name:        t.Name(),
+			Description: t.Description(),
+			Safety:      tool.SafetyOf(t),
+			Annotations: tool.AnnotationsOf(t),
+			Schema:      t.Schema(),
+		})
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (g *Gateway) openAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, g.Tools.OpenAPI(g.Title, g.Version, g.baseURL(r)))
+}
+
+// baseURL returns the URL the API is reached at by the client of r.
+func (g *Gateway) baseURL(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if g.trusted(r) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+			host = fwd
+		}
+	}
+	return scheme + "://" + host
+}
+
+// trusted reports whether r comes from a trusted proxy.
+func (g *Gateway) trusted(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range g.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Gateway) call(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	t := g.Tools.Get(name)
+	if t == nil {
+		writeError(w, http.StatusNotFound, &Error{Error: fmt.Sprintf("%s: %s", tool.ErrNotFound, name)})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, &Error{Error: err.Error()})
+		return
+	}
+	arguments := strings.TrimSpace(string(body))
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	out, err := t.Call(r.Context(), arguments)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		status, e := errorOf(err)
+		writeError(w, status, e)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]json.RawMessage{"result": tool.Result(t, out)})
+}
+
+// Error is the body of error responses.
+type Error struct {
+	Error string `json:"error"`
+
+	// Problems lists what's wrong with the arguments of a call.
+	Problems []string `json:"problems,omitempty"`
+
+	// Details are the output of the failed command of a function.
+	Details string `json:"details,omitempty"`
+}
+
+// errorOf maps the error of a call to a status and a response.
+func errorOf(err error) (int, *Error) {
+	var (
+		argsErr   *tool.ArgumentsError
+		deniedErr *tool.DeniedError
+		execErr   *tool.ExecError
+		queryErr  *tool.QueryError
+	)
+	switch {
+	case errors.As(err, &argsErr):
+		return http.StatusBadRequest, &Error{Error: err.Error(), Problems: argsErr.Problems}
+	case errors.As(err, &deniedErr):
+		return http.StatusForbidden, &Error{Error: err.Error()}
+	case errors.As(err, &execErr):
+		return http.StatusBadGateway, &Error{Error: err.Error(), Details: execErr.Compact()}
+	case errors.As(err, &queryErr):
+		return http.StatusBadGateway, &Error{Error: err.Error()}
+	}
+	return http.StatusInternalServerError, &Error{Error: err.Error()}
+}
+
+func writeError(w http.ResponseWriter, status int, e *Error) {
+	writeJSON(w, status, e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write response: %v\n", err)
+	}
+}
@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/aluzzardi/langdag/tool"
+)
+
+type greetArgs struct {
+	Name string `json:"name"`
+}
+
+func newGateway() *Gateway {
+	greet := tool.Func("greet", "Greet someone.", func(_ context.Context, args greetArgs) (string, error) {
+		return "hello " + args.Name, nil
+	})
+	remove := tool.Func("remove", "Remove everything.", func(context.Context, struct{}) (string, error) {
+		return "removed", nil
+	})
+	remove.SetSafety(tool.Destructive)
+	tools := tool.Tools{greet, remove}
+	tools.RequireApproval(tool.Destructive, tool.DenyAll)
+	return New(tools)
+}
+
+// serve sends a request to g and decodes the JSON response into v.
+func serve(t *testing.T, g *Gateway, r *http.Request, v any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body, err)
+	}
+	return w.Code
+}
+
+func TestCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"ok", "/tools/greet", `{"name": "world"}`, http.StatusOK, `{"result":"hello world"}`},
+		{"not found", "/tools/missing", `{}`, http.StatusNotFound, `{"error":"tool not found: missing"}`},
+		{"invalid JSON", "/tools/greet", `{`, http.StatusBadRequest, ""},
+		{"denied", "/tools/remove", "", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got json.RawMessage
+			status := serve(t, newGateway(), httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)), &got)
+			if status != tt.status {
+				t.Errorf("status = %d, want %d: %s", status, tt.status, got)
+			}
+			if tt.want != "" {
+				compact, _ := json.Marshal(got)
+				if string(compact) != tt.want {
+					t.Errorf("got %s, want %s", compact, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCallProblems(t *testing.T) {
+	var got Error
+	status := serve(t, newGateway(), httptest.NewRequest(http.MethodPost, "/tools/greet", strings.NewReader(`{"name": 1}`)), &got)
+	if status != http.StatusBadRequest || len(got.Problems) == 0 {
+		t.Errorf("status = %d, problems = %v", status, got.Problems)
+	}
+}
+
+func TestList(t *testing.T) {
+	var got []Entry
+	if status := serve(t, newGateway(), httptest.NewRequest(http.MethodGet, "/tools", nil), &got); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if len(got) != 2 || got[0].Name != "greet" || got[1].Safety != tool.Destructive {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestToken(t *testing.T) {
+	g := newGateway()
+	g.Token = "secret"
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		r := httptest.NewRequest(http.MethodGet, "/tools", nil)
+		r.Header.Set("Authorization", auth)
+		var got Error
+		if status := serve(t, g, r, &got); status != http.StatusUnauthorized {
+			t.Errorf("%q: status = %d, want %d", auth, status, http.StatusUnauthorized)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/tools", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	var got []Entry
+	if status := serve(t, g, r, &got); status != http.StatusOK {
+		t.Errorf("status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestOpenAPIServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		trusted []netip.Prefix
+		want    string
+	}{
+		{"untrusted", "203.0.113.7:4321", nil, "http://example.com"},
+		{"other proxy", "203.0.113.7:4321", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, "http://example.com"},
+		{"trusted", "10.1.2.3:4321", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, "https://api.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGateway()
+			g.TrustedProxies = tt.trusted
+			r := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+			r.RemoteAddr = tt.remote
+			r.Header.Set("X-Forwarded-Proto", "https")
+			r.Header.Set("X-Forwarded-Host", "api.example.com")
+			var doc struct {
+				Servers []struct {
+					URL string `json:"url"`
+				} `json:"servers"`
+			}
+			serve(t, g, r, &doc)
+			if len(doc.Servers) != 1 || doc.Servers[0].URL != tt.want {
+				t.Errorf("servers = %+v, want %s", doc.Servers, tt.want)
+			}
+		})
+	}
+}
+
+// brokenBody fails reads, like the body of a client that disconnected.
+type brokenBody struct{}
+
+func (brokenBody) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestCallBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   io.Reader
+		status int
+	}{
+		{"too large", strings.NewReader(`{"name": "` + strings.Repeat("a", MaxBodySize) + `"}`), http.StatusRequestEntityTooLarge},
+		{"broken", brokenBody{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Error
+			if status := serve(t, newGateway(), httptest.NewRequest(http.MethodPost, "/tools/greet", tt.body), &got); status != tt.status {
+				t.Errorf("status = %d, want %d: %s", status, tt.status, got.Error)
+			}
+		})
+	}
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]any `json:"responses"`
+		} `json:"paths"`
+	}
+	serve(t, newGateway(), httptest.NewRequest(http.MethodGet, "/openapi.json", nil), &doc)
+	responses := doc.Paths["/tools/greet"]["post"].Responses
+	for _, status := range []string{"200", "400", "403", "404", "413", "500", "502"} {
+		if responses[status] == nil {
+			t.Errorf("no %s response in %v", status, responses)
+		}
+	}
+}
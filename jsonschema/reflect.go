@@ -0,0 +1,131 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// For returns the schema of the JSON encoding of T.
+//
+// Properties are named after their `json` tag. Fields tagged `omitempty` and
+// pointers are optional, other fields are required. Struct fields may be
+// documented with a `description` tag and restricted with an `enum` tag
+// holding comma separated values.
+func For[T any]() *Schema {
+	return Reflect(reflect.TypeFor[T]())
+}
+
+func Reflect(t reflect.Type) *Schema {
+	return reflectType(t, map[reflect.Type]bool{})
+}
+
+var (
+	timeType           = reflect.TypeFor[time.Time]()
+	rawMessageType     = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType  = reflect.TypeFor[json.Marshaler]()
+	emptyInterfaceType = reflect.TypeFor[any]()
+)
+
+func reflectType(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType, t == emptyInterfaceType:
+		return &Schema{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom encodings can't be described.
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string.
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: reflectType(t.Elem(), seen)}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		if seen[t] {
+			// Recursive types can't be inlined.
+			return &Schema{Type: "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return reflectStruct(t, seen)
+	case reflect.Interface:
+		return &Schema{}
+	}
+
+	panic(fmt.Sprintf("jsonschema: unsupported type %s", t))
+}
+
+func reflectStruct(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := reflectStruct(f.Type, seen)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := reflectType(f.Type, seen)
+		prop.Description = f.Tag.Get("description")
+		if enum := f.Tag.Get("enum"); enum != "" {
+			for _, v := range strings.Split(enum, ",") {
+				prop.Enum = append(prop.Enum, v)
+			}
+		}
+		s.Properties[name] = prop
+
+		optional := f.Type.Kind() == reflect.Pointer
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "omitempty" || opt == "omitzero" {
+				optional = true
+			}
+		}
+		if !optional {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,285 @@
+// Package jsonschema implements the subset of JSON Schema used to describe
+// tool arguments and structured model outputs.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type Schema struct {
+	Type        string `json:"type,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Objects
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+
+	// Arrays
+	Items *Schema `json:"items,omitempty"`
+
+	Enum    []any     `json:"enum,omitempty"`
+	AnyOf   []*Schema `json:"anyOf,omitempty"`
+	Default any       `json:"default,omitempty"`
+	Format  string    `json:"format,omitempty"`
+	Minimum *float64  `json:"minimum,omitempty"`
+	Maximum *float64  `json:"maximum,omitempty"`
+
+	// Raw is the document a schema was decoded from, when it uses keywords
+	// or forms outside of the subset above: "$ref", "oneOf", "pattern", type
+	// arrays... Such schemas are encoded as Raw, for models to see them
+	// whole, and validated against the subset only.
+	Raw json.RawMessage `json:"-"`
+}
+
+// plain is a Schema without its JSON methods.
+type plain Schema
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.Raw != nil {
+		return s.Raw, nil
+	}
+	return json.Marshal((*plain)(s))
+}
+
+// UnmarshalJSON decodes the subset of JSON Schema this package understands,
+// keeping the document in Raw if it's not all there is. Type arrays are
+// decoded as anyOf, and additionalProperties schemas as allowing any
+// property.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var doc struct {
+		plain
+		Type                 json.RawMessage `json:"type,omitempty"`
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	*s = Schema(doc.plain)
+
+	if len(doc.Type) > 0 {
+		var types []string
+		if err := json.Unmarshal(doc.Type, &s.Type); err != nil {
+			if err := json.Unmarshal(doc.Type, &types); err != nil {
+				return fmt.Errorf("type must be a string or an array of strings")
+			}
+		}
+		if len(s.AnyOf) == 0 && len(types) > 0 {
+			for _, t := range types {
+				s.AnyOf = append(s.AnyOf, &Schema{Type: t})
+			}
+		}
+	}
+	if len(doc.AdditionalProperties) > 0 {
+		var allowed bool
+		if json.Unmarshal(doc.AdditionalProperties, &allowed) == nil {
+			s.AdditionalProperties = &allowed
+		}
+	}
+
+	if lossy(s, data) {
+		s.Raw = slices.Clone(data)
+	}
+	return nil
+}
+
+// lossy reports whether encoding s doesn't give back data.
+func lossy(s *Schema, data []byte) bool {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return true
+	}
+	var a, b any
+	if json.Unmarshal(data, &a) != nil || json.Unmarshal(encoded, &b) != nil {
+		return true
+	}
+	return !reflect.DeepEqual(a, b)
+}
+
+// Map returns the schema as a generic map, as expected by API clients.
+func (s *Schema) Map() map[string]any {
+	data, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	m := map[string]any{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Parse decodes a schema from its JSON representation.
+func Parse(data []byte) (*Schema, error) {
+	s := &Schema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return s, nil
+}
+
+// FromMap decodes a schema from a generic map.
+func FromMap(m map[string]any) (*Schema, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// ValidationError lists every mismatch between a value and a schema.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateJSON decodes data and validates it against the schema.
+func (s *Schema) ValidateJSON(data []byte) (any, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	return v, s.Validate(v)
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json into an
+// `any`) against the schema.
+func (s *Schema) Validate(v any) error {
+	problems := s.validate("$", v, nil)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, v any, problems []string) []string {
+	if s == nil {
+		return problems
+	}
+
+	if len(s.AnyOf) > 0 {
+		for _, alt := range s.AnyOf {
+			if len(alt.validate(path, v, nil)) == 0 {
+				return problems
+			}
+		}
+		return append(problems, fmt.Sprintf("%s: does not match any of the allowed schemas", path))
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return equal(e, v) }) {
+		problems = append(problems, fmt.Sprintf("%s: must be one of %s", path, formatEnum(s.Enum)))
+	}
+
+	if s.Type != "" && !hasType(v, s.Type) {
+		return append(problems, fmt.Sprintf("%s: expected %s, got %s", path, s.Type, typeOf(v)))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					problems = append(problems, fmt.Sprintf("%s: unexpected property %q", path, k))
+				}
+				continue
+			}
+			problems = prop.validate(path+"."+k, v[k], problems)
+		}
+	case []any:
+		for i, item := range v {
+			problems = s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: must be >= %v", path, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: must be <= %v", path, *s.Maximum))
+		}
+	}
+
+	return problems
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return v == nil
+	}
+	return true
+}
+
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func equal(a, b any) bool {
+	da, _ := json.Marshal(a)
+	db, _ := json.Marshal(b)
+	return string(da) == string(db)
+}
+
+func formatEnum(values []any) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		data, _ := json.Marshal(v)
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, ", ")
+}
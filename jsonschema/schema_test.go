@@ -0,0 +1,219 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	min, max := 1.0, 10.0
+	closed := false
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":   {Type: "string"},
+			"count":  {Type: "integer", Minimum: &min, Maximum: &max},
+			"state":  {Type: "string", Enum: []any{"open", "closed"}},
+			"labels": {Type: "array", Items: &Schema{Type: "string"}},
+			"id":     {AnyOf: []*Schema{{Type: "string"}, {Type: "integer"}}},
+		},
+		Required:             []string{"name"},
+		AdditionalProperties: &closed,
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		problems []string
+	}{
+		{
+			name:  "valid",
+			value: `{"name": "x", "count": 3, "state": "open", "labels": ["a", "b"], "id": 42}`,
+		},
+		{
+			name:     "missing required",
+			value:    `{}`,
+			problems: []string{`$: missing required property "name"`},
+		},
+		{
+			name:     "wrong type",
+			value:    `{"name": 1}`,
+			problems: []string{"$.name: expected string, got integer"},
+		},
+		{
+			name:     "not an integer",
+			value:    `{"name": "x", "count": 1.5}`,
+			problems: []string{"$.count: expected integer, got number"},
+		},
+		{
+			name:     "out of range",
+			value:    `{"name": "x", "count": 11}`,
+			problems: []string{"$.count: must be <= 10"},
+		},
+		{
+			name:     "enum",
+			value:    `{"name": "x", "state": "merged"}`,
+			problems: []string{`$.state: must be one of "open", "closed"`},
+		},
+		{
+			name:     "items",
+			value:    `{"name": "x", "labels": ["a", 2]}`,
+			problems: []string{"$.labels[1]: expected string, got integer"},
+		},
+		{
+			name:     "any of",
+			value:    `{"name": "x", "id": true}`,
+			problems: []string{"$.id: does not match any of the allowed schemas"},
+		},
+		{
+			name:     "additional property",
+			value:    `{"name": "x", "extra": 1}`,
+			problems: []string{`$: unexpected property "extra"`},
+		},
+		{
+			name:     "every problem",
+			value:    `{"count": 0, "extra": 1}`,
+			problems: []string{`$: missing required property "name"`, "$.count: must be >= 1", `$: unexpected property "extra"`},
+		},
+		{
+			name:     "invalid JSON",
+			value:    `{`,
+			problems: []string{"invalid JSON: unexpected end of JSON input"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := schema.ValidateJSON([]byte(tt.value))
+			if tt.problems == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a ValidationError, got %v", err)
+			}
+			if !reflect.DeepEqual(verr.Problems, tt.problems) {
+				t.Errorf("problems = %q, want %q", verr.Problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`{"type": "object", "properties": {"n": {"type": "integer", "description": "a number"}}, "required": ["n"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Properties["n"].Type != "integer" || s.Properties["n"].Description != "a number" {
+		t.Errorf("unexpected property: %+v", s.Properties["n"])
+	}
+	if !reflect.DeepEqual(s.Required, []string{"n"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+}
+
+func TestParseRaw(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		valid  []string
+		wrong  []string
+		raw    bool
+	}{
+		{
+			name:   "subset",
+			schema: `{"type": "object", "properties": {"n": {"type": "integer"}}, "required": ["n"]}`,
+			valid:  []string{`{"n": 1}`},
+			wrong:  []string{`{}`, `{"n": "1"}`},
+		},
+		{
+			name:   "type array",
+			schema: `{"type": ["string", "null"]}`,
+			valid:  []string{`"a"`, `null`},
+			wrong:  []string{`1`},
+			raw:    true,
+		},
+		{
+			name:   "additionalProperties schema",
+			schema: `{"type": "object", "additionalProperties": {"type": "string"}}`,
+			valid:  []string{`{"a": "b"}`},
+			wrong:  []string{`[]`},
+			raw:    true,
+		},
+		{
+			name:   "closed object",
+			schema: `{"type": "object", "properties": {"a": {"type": "string"}}, "additionalProperties": false}`,
+			valid:  []string{`{"a": "b"}`},
+			wrong:  []string{`{"b": "a"}`},
+		},
+		{
+			name:   "unknown keywords",
+			schema: `{"type": "object", "properties": {"r": {"$ref": "#/$defs/r"}, "p": {"type": "string", "pattern": "^a"}, "o": {"oneOf": [{"type": "string"}]}}, "$defs": {"r": {"type": "integer"}}}`,
+			valid:  []string{`{"r": "anything", "p": "b", "o": 1}`},
+			wrong:  []string{`{"p": 1}`},
+			raw:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (s.Raw != nil) != tt.raw {
+				t.Errorf("raw = %s, want raw %v", s.Raw, tt.raw)
+			}
+			want := map[string]any{}
+			json.Unmarshal([]byte(tt.schema), &want)
+			if got := s.Map(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Map() = %v, want %v", got, want)
+			}
+			for _, v := range tt.valid {
+				if _, err := s.ValidateJSON([]byte(v)); err != nil {
+					t.Errorf("%s: %v", v, err)
+				}
+			}
+			for _, v := range tt.wrong {
+				if _, err := s.ValidateJSON([]byte(v)); err == nil {
+					t.Errorf("%s is valid", v)
+				}
+			}
+		})
+	}
+
+	if _, err := Parse([]byte(`{"type": 1}`)); err == nil {
+		t.Error("invalid type was parsed")
+	}
+}
+
+func TestReflect(t *testing.T) {
+	type args struct {
+		Repo   string   `json:"repo" description:"The repository"`
+		Limit  int      `json:"limit,omitempty"`
+		Labels []string `json:"labels,omitempty"`
+		Hidden string   `json:"-"`
+	}
+	s := For[args]()
+	if s.Type != "object" {
+		t.Fatalf("type = %q", s.Type)
+	}
+	if got := s.Properties["repo"]; got == nil || got.Type != "string" || got.Description != "The repository" {
+		t.Errorf("repo = %+v", got)
+	}
+	if got := s.Properties["limit"]; got == nil || got.Type != "integer" {
+		t.Errorf("limit = %+v", got)
+	}
+	if got := s.Properties["labels"]; got == nil || got.Type != "array" || got.Items.Type != "string" {
+		t.Errorf("labels = %+v", got)
+	}
+	if _, ok := s.Properties["Hidden"]; ok {
+		t.Errorf("ignored field is in the schema")
+	}
+	if !reflect.DeepEqual(s.Required, []string{"repo"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+}
@@ -0,0 +1,92 @@
+package jsonschema
+
+import "slices"
+
+// Strict returns a copy of the schema meeting the requirements of strict
+// structured outputs: objects forbid additional properties and require all
+// their properties, optional ones accepting null instead. It reports false if
+// the schema can't be made strict, as objects without properties (maps) and
+// schemas accepting anything can't be described, nor can keywords outside of
+// the subset of this package.
+func (s *Schema) Strict() (*Schema, bool) {
+	if s == nil || s.Raw != nil {
+		return nil, false
+	}
+	strict := *s
+	// Defaults are not supported.
+	strict.Default = nil
+
+	if len(s.AnyOf) > 0 {
+		strict.AnyOf = make([]*Schema, len(s.AnyOf))
+		for i, alt := range s.AnyOf {
+			var ok bool
+			if strict.AnyOf[i], ok = alt.Strict(); !ok {
+				return nil, false
+			}
+		}
+		return &strict, true
+	}
+
+	switch s.Type {
+	case "":
+		if len(s.Enum) == 0 {
+			return nil, false
+		}
+	case "object":
+		if len(s.Properties) == 0 {
+			return nil, false
+		}
+		strict.Properties = make(map[string]*Schema, len(s.Properties))
+		strict.Required = make([]string, 0, len(s.Properties))
+		for name, prop := range s.Properties {
+			p, ok := prop.Strict()
+			if !ok {
+				return nil, false
+			}
+			if !slices.Contains(s.Required, name) {
+				p = &Schema{AnyOf: []*Schema{p, {Type: "null"}}}
+			}
+			strict.Properties[name] = p
+			strict.Required = append(strict.Required, name)
+		}
+		slices.Sort(strict.Required)
+		strict.AdditionalProperties = new(bool)
+	case "array":
+		if s.Items == nil {
+			return nil, false
+		}
+		var ok bool
+		if strict.Items, ok = s.Items.Strict(); !ok {
+			return nil, false
+		}
+	}
+	return &strict, true
+}
+
+// DropNulls removes from v, a decoded JSON value, the null optional
+// properties the strict variant of the schema accepts, so that it validates
+// against the schema itself.
+func (s *Schema) DropNulls(v any) any {
+	if s == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for name, value := range v {
+			prop, ok := s.Properties[name]
+			if !ok {
+				continue
+			}
+			if value == nil && !slices.Contains(s.Required, name) {
+				delete(v, name)
+				continue
+			}
+			v[name] = prop.DropNulls(value)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = s.Items.DropNulls(item)
+		}
+	}
+	return v
+}
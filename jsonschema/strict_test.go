@@ -0,0 +1,71 @@
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *Schema
+		ok     bool
+	}{
+		{"string", &Schema{Type: "string"}, true},
+		{"enum", &Schema{Enum: []any{"a", "b"}}, true},
+		{"any", &Schema{}, false},
+		{"map", &Schema{Type: "object"}, false},
+		{"array of any", &Schema{Type: "array", Items: &Schema{}}, false},
+		{"nested map", &Schema{Type: "object", Properties: map[string]*Schema{"m": {Type: "object"}}}, false},
+		{"anyOf", &Schema{AnyOf: []*Schema{{Type: "string"}, {Type: "integer"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := tt.schema.Strict(); ok != tt.ok {
+				t.Errorf("ok = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestStrictObject(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+		Note string `json:"note,omitempty"`
+	}
+	type args struct {
+		Repo  string `json:"repo"`
+		Limit int    `json:"limit,omitempty"`
+		Items []item `json:"items"`
+	}
+	s := For[args]()
+	strict, ok := s.Strict()
+	if !ok {
+		t.Fatal("schema can't be made strict")
+	}
+	if !reflect.DeepEqual(strict.Required, []string{"items", "limit", "repo"}) || *strict.AdditionalProperties {
+		t.Errorf("strict = %+v", strict)
+	}
+	if limit := strict.Properties["limit"]; len(limit.AnyOf) != 2 || limit.AnyOf[1].Type != "null" {
+		t.Errorf("limit = %+v", limit)
+	}
+	if items := strict.Properties["items"].Items; !reflect.DeepEqual(items.Required, []string{"name", "note"}) {
+		t.Errorf("items = %+v", items)
+	}
+	if s.AdditionalProperties != nil || len(s.Required) != 2 {
+		t.Errorf("original schema was modified: %+v", s)
+	}
+
+	v, err := strict.ValidateJSON([]byte(`{"repo": "r", "limit": null, "items": [{"name": "n", "note": null}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v = s.DropNulls(v)
+	want := map[string]any{"repo": "r", "items": []any{map[string]any{"name": "n"}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("DropNulls = %v, want %v", v, want)
+	}
+	if err := s.Validate(v); err != nil {
+		t.Error(err)
+	}
+}
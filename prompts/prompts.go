@@ -0,0 +1,243 @@
+// Package prompts implements parameterized missions, such as "scan pull
+// request {{.pr}} of {{.repo}} for leaked secrets", loaded from a config file
+// and served as MCP prompts.
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Prompt is a mission template. Template is a text/template referring to
+// arguments by name: {{.repo}}.
+type Prompt struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Arguments   []*Argument `json:"arguments,omitempty"`
+	Template    string      `json:"template"`
+
+	tmpl *template.Template
+}
+
+// Argument is a typed argument of a prompt.
+type Argument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// Type is one of string (the default), integer or boolean.
+	Type     string `json:"type,omitempty"`
+	Required bool   `json:"required,omitempty"`
+
+	// Default is the value of optional arguments that aren't set.
+	Default string `json:"default,omitempty"`
+}
+
+// Prompts is a set of prompts, as configured in a JSON file:
+//
+//	{
+//	  "prompts": [
+//	    {
+//	      "name": "scan-pr",
+//	      "description": "Scan a pull request for leaked secrets",
+//	      "arguments": [
+//	        {"name": "repo", "required": true},
+//	        {"name": "pr", "type": "integer", "required": true}
+//	      ],
+//	      "template": "Scan pull request #{{.pr}} of {{.repo}} for leaked secrets."
+//	    }
+//	  ]
+//	}
+type Prompts []*Prompt
+
+// Load reads prompts from a JSON config file.
+func Load(path string) (Prompts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load prompts from %s: %w", path, err)
+	}
+	return prompts, nil
+}
+
+// Parse decodes and checks a prompts config.
+func Parse(data []byte) (Prompts, error) {
+	var config struct {
+		Prompts Prompts `json:"prompts"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, p := range config.Prompts {
+		if p.Name == "" {
+			return nil, fmt.Errorf("prompt without a name")
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("duplicate prompt %q", p.Name)
+		}
+		seen[p.Name] = true
+		if err := p.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return config.Prompts, nil
+}
+
+func (p *Prompt) compile() error {
+	for _, arg := range p.Arguments {
+		switch arg.Type {
+		case "", "string", "integer", "boolean":
+		default:
+			return fmt.Errorf("prompt %s: argument %s: unsupported type %q", p.Name, arg.Name, arg.Type)
+		}
+	}
+	tmpl, err := template.New(p.Name).Option("missingkey=error").Parse(p.Template)
+	if err != nil {
+		return fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	p.tmpl = tmpl
+	return nil
+}
+
+// Get returns the prompt named name, or nil.
+func (p Prompts) Get(name string) *Prompt {
+	for _, prompt := range p {
+		if prompt.Name == name {
+			return prompt
+		}
+	}
+	return nil
+}
+
+// Expand renders the prompt with the given arguments, which are checked
+// against their declared type.
+func (p *Prompt) Expand(args map[string]string) (string, error) {
+	if p.tmpl == nil {
+		if err := p.compile(); err != nil {
+			return "", err
+		}
+	}
+
+	values := map[string]any{}
+	problems := []string{}
+	declared := map[string]bool{}
+	for _, arg := range p.Arguments {
+		declared[arg.Name] = true
+		raw, ok := args[arg.Name]
+		if !ok || raw == "" {
+			if arg.Required {
+				problems = append(problems, fmt.Sprintf("missing required argument %q", arg.Name))
+				continue
+			}
+			raw = arg.Default
+		}
+		v, err := arg.parse(raw)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		values[arg.Name] = v
+	}
+	for name := range args {
+		if !declared[name] {
+			problems = append(problems, fmt.Sprintf("unknown argument %q", name))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return "", fmt.Errorf("prompt %s: %s", p.Name, strings.Join(problems, "; "))
+	}
+
+	sb := new(strings.Builder)
+	if err := p.tmpl.Execute(sb, values); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return sb.String(), nil
+}
+
+func (a *Argument) parse(raw string) (any, error) {
+	switch a.Type {
+	case "integer":
+		if raw == "" {
+			return 0, nil
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: expected integer, got %q", a.Name, raw)
+		}
+		return v, nil
+	case "boolean":
+		if raw == "" {
+			return false, nil
+		}
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: expected boolean, got %q", a.Name, raw)
+		}
+		return v, nil
+	}
+	return raw, nil
+}
+
+// Usage describes how to call the prompt, e.g. `scan-pr repo=<string> [pr=<integer>]`.
+func (p *Prompt) Usage() string {
+	parts := []string{p.Name}
+	for _, arg := range p.Arguments {
+		typ := arg.Type
+		if typ == "" {
+			typ = "string"
+		}
+		part := fmt.Sprintf("%s=<%s>", arg.Name, typ)
+		if !arg.Required {
+			part = "[" + part + "]"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ToMCP returns the definition of a prompt for MCP servers.
+//
+// MCP prompt arguments are strings: their type is mentioned in their
+// description.
+func ToMCP(p *Prompt) mcp.Prompt {
+	opts := []mcp.PromptOption{mcp.WithPromptDescription(p.Description)}
+	for _, arg := range p.Arguments {
+		description := arg.Description
+		if arg.Type != "" && arg.Type != "string" {
+			description = strings.TrimSpace(fmt.Sprintf("%s (%s)", description, arg.Type))
+		}
+		argOpts := []mcp.ArgumentOption{mcp.ArgumentDescription(description)}
+		if arg.Required {
+			argOpts = append(argOpts, mcp.RequiredArgument())
+		}
+		opts = append(opts, mcp.WithArgument(arg.Name, argOpts...))
+	}
+	return mcp.NewPrompt(p.Name, opts...)
+}
+
+// MCPHandler returns an MCP server handler expanding p into a user message.
+func MCPHandler(p *Prompt) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		text, err := p.Expand(request.Params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewGetPromptResult(p.Description, []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+		}), nil
+	}
+}
@@ -0,0 +1,150 @@
+package prompts
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const config = `{
+  "prompts": [
+    {
+      "name": "scan-pr",
+      "description": "Scan a pull request for leaked secrets",
+      "arguments": [
+        {"name": "repo", "required": true},
+        {"name": "pr", "type": "integer", "required": true},
+        {"name": "verified", "type": "boolean", "default": "true"}
+      ],
+      "template": "Scan pull request #{{.pr}} of {{.repo}}{{if .verified}}, reporting verified secrets only{{end}}."
+    }
+  ]
+}`
+
+func scanPR(t *testing.T) *Prompt {
+	t.Helper()
+	prompts, err := Parse([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := prompts.Get("scan-pr")
+	if p == nil {
+		t.Fatal("scan-pr not found")
+	}
+	return p
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]string
+		want string
+	}{
+		{
+			name: "default",
+			args: map[string]string{"repo": "acme/api", "pr": "42"},
+			want: "Scan pull request #42 of acme/api, reporting verified secrets only.",
+		},
+		{
+			name: "boolean",
+			args: map[string]string{"repo": "acme/api", "pr": "42", "verified": "false"},
+			want: "Scan pull request #42 of acme/api.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scanPR(t).Expand(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]string
+		want []string
+	}{
+		{
+			name: "missing",
+			args: map[string]string{"pr": "42"},
+			want: []string{`missing required argument "repo"`},
+		},
+		{
+			name: "empty",
+			args: map[string]string{"repo": "", "pr": ""},
+			want: []string{`missing required argument "pr"`, `missing required argument "repo"`},
+		},
+		{
+			name: "type",
+			args: map[string]string{"repo": "acme/api", "pr": "latest"},
+			want: []string{`argument "pr": expected integer, got "latest"`},
+		},
+		{
+			name: "unknown",
+			args: map[string]string{"repo": "acme/api", "pr": "42", "branch": "main"},
+			want: []string{`unknown argument "branch"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := scanPR(t).Expand(tt.args)
+			if err == nil {
+				t.Fatal("Expand() succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expand() = %v, want %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandUndeclared(t *testing.T) {
+	p := &Prompt{Name: "p", Template: "Scan {{.repo}}."}
+	if _, err := p.Expand(nil); err == nil || !strings.Contains(err.Error(), "repo") {
+		t.Errorf("Expand() = %v, want an error about repo", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"no name":   `{"prompts": [{"template": "x"}]}`,
+		"duplicate": `{"prompts": [{"name": "a", "template": "x"}, {"name": "a", "template": "y"}]}`,
+		"type":      `{"prompts": [{"name": "a", "arguments": [{"name": "n", "type": "float"}], "template": "x"}]}`,
+		"template":  `{"prompts": [{"name": "a", "template": "{{.x"}]}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: Parse() succeeded", name)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if got, want := scanPR(t).Usage(), "scan-pr repo=<string> pr=<integer> [verified=<boolean>]"; got != want {
+		t.Errorf("Usage() = %q, want %q", got, want)
+	}
+}
+
+func TestMCPHandler(t *testing.T) {
+	req := mcp.GetPromptRequest{}
+	req.Params.Arguments = map[string]string{"repo": "acme/api", "pr": "42", "verified": "false"}
+	res, err := MCPHandler(scanPR(t))(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Messages) != 1 || res.Messages[0].Role != mcp.RoleUser {
+		t.Fatalf("messages = %+v", res.Messages)
+	}
+	if text, ok := res.Messages[0].Content.(mcp.TextContent); !ok || text.Text != "Scan pull request #42 of acme/api." {
+		t.Errorf("content = %+v", res.Messages[0].Content)
+	}
+}
@@ -0,0 +1,464 @@
+// Package proxy implements an OpenAI compatible chat completions endpoint
+// giving models the tools of dagger modules.
+//
+// Clients send conversations to POST /v1/chat/completions as they would to
+// OpenAI. The proxy adds the tools, runs the tool calls of the model against
+// the upstream provider until it answers, and returns that final answer only.
+// Any OpenAI client or chat UI can use modules this way, without knowing
+// about them.
+//
+// Sampling parameters such as temperature and max_tokens are passed on to
+// every model call of the tool loop. Requests with parameters the proxy
+// can't honor, like n or logprobs, are rejected.
+//
+// Streams are not streamed token by token: while tools run, they carry empty
+// chunks keeping the connection alive, then the whole answer in a single
+// chunk.
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aluzzardi/langdag/agent"
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// MaxBodySize is the size of the largest request body accepted.
+const MaxBodySize = 4 << 20
+
+// Server is an http.Handler serving chat completions.
+type Server struct {
+	// Client calls the upstream provider.
+	Client *openai.Client
+	Tools  tool.Tools
+
+	// Model, if set, is used instead of the model requested by clients.
+	Model openai.ChatModel
+
+	// Token, if set, is the bearer token clients must send.
+	Token string
+
+	// Limits bound the tool loop of every request. Defaults to
+	// agent.DefaultLimits.
+	Limits agent.Limits
+
+	// MaxTokens, if set, aborts requests using more tokens, tool loop
+	// included.
+	MaxTokens int64
+
+	// KeepAlive is the interval of the empty chunks sent on streams while
+	// tools run, so that clients and proxies don't time out. Defaults to 10s.
+	KeepAlive time.Duration
+
+	mux *http.ServeMux
+}
+
+// New returns a server giving tools to the models of client.
+func New(client *openai.Client, tools tool.Tools) *Server {
+	s := &Server{
+		Client:    client,
+		Tools:     tools,
+		Limits:    agent.DefaultLimits,
+		KeepAlive: 10 * time.Second,
+		mux:       http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /v1/chat/completions", s.completions)
+	s.mux.HandleFunc("GET /v1/models", s.models)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(s.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "unauthorized")
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// forwarded are the parameters of chat completion requests passed on to the
+// upstream provider.
+var forwarded = []string{
+	"temperature", "top_p", "max_tokens", "max_completion_tokens",
+	"presence_penalty", "frequency_penalty", "stop", "seed", "user",
+	"reasoning_effort", "response_format",
+}
+
+// chatRequest is the subset of chat completion requests the proxy supports.
+type chatRequest struct {
+	Model         string        `json:"model"`
+	Messages      []chatMessage `json:"messages"`
+	Stream        bool          `json:"stream"`
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Tools []json.RawMessage `json:"tools"`
+
+	// options set the forwarded parameters.
+	options []option.RequestOption
+}
+
+// parseRequest decodes a chat completion request, checking the proxy supports
+// all of its parameters.
+func parseRequest(body []byte) (*chatRequest, error) {
+	req := &chatRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	params := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := params[key]
+		switch {
+		case string(value) == "null":
+		case key == "model", key == "messages", key == "stream", key == "stream_options", key == "tools":
+		case key == "n":
+			if string(value) != "1" {
+				return nil, errors.New("n must be 1: the proxy answers with a single choice")
+			}
+		case slices.Contains(forwarded, key):
+			req.options = append(req.options, option.WithJSONSet(key, value))
+		default:
+			return nil, fmt.Errorf("unsupported parameter %q", key)
+		}
+	}
+	if len(req.Tools) > 0 {
+		return nil, errors.New("tools are provided by the server: requests can't declare their own")
+	}
+	return req, nil
+}
+
+type chatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCallID string          `json:"tool_call_id"`
+	ToolCalls  []struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+// text returns the content of a message: either a string or text parts.
+func (m chatMessage) text() (string, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", fmt.Errorf("invalid content of %s message", m.Role)
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("unsupported %s content in %s message: only text is supported", part.Type, m.Role)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// conversation converts the messages of a request into the history of the
+// conversation and the user input to answer. Tool messages must answer the
+// tool calls of a previous assistant message.
+func (req *chatRequest) conversation() ([]*agent.Message, string, error) {
+	if len(req.Messages) == 0 {
+		return nil, "", errors.New("messages must not be empty")
+	}
+	calls := map[string]bool{}
+	history := make([]*agent.Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		content, err := m.text()
+		if err != nil {
+			return nil, "", err
+		}
+		switch agent.Role(m.Role) {
+		case agent.RoleSystem, "developer":
+			history = append(history, agent.SystemMessage(content))
+		case agent.RoleUser:
+			history = append(history, agent.UserMessage(content))
+		case agent.RoleAssistant:
+			msg := &agent.Message{Role: agent.RoleAssistant, Content: content}
+			for _, call := range m.ToolCalls {
+				if call.ID == "" || call.Function.Name == "" {
+					return nil, "", errors.New("tool calls of assistant messages need an id and a function name")
+				}
+				if call.Type != "" && call.Type != "function" {
+					return nil, "", fmt.Errorf("unsupported %s tool call in assistant message", call.Type)
+				}
+				calls[call.ID] = true
+				msg.ToolCalls = append(msg.ToolCalls, agent.ToolCall{
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				})
+			}
+			history = append(history, msg)
+		case agent.RoleTool:
+			if !calls[m.ToolCallID] {
+				return nil, "", fmt.Errorf("tool message answers unknown tool call %q", m.ToolCallID)
+			}
+			history = append(history, agent.ToolMessage(m.ToolCallID, content))
+		default:
+			return nil, "", fmt.Errorf("unsupported role %q", m.Role)
+		}
+	}
+	last := history[len(history)-1]
+	if last.Role != agent.RoleUser {
+		return nil, "", fmt.Errorf("the last message must be a user message, got %s", last.Role)
+	}
+	return history[:len(history)-1], last.Content, nil
+}
+
+func (s *Server) completions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", err.Error())
+		return
+	}
+	req, err := parseRequest(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	history, input, err := req.conversation()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	model := s.Model
+	if model == "" {
+		model = openai.ChatModel(req.Model)
+	}
+	if model == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+
+	a := agent.New(s.Client, s.Tools)
+	a.Model = model
+	a.Limits = s.Limits
+	a.RequestOptions = req.options
+	a.Meter = agent.NewMeter(nil, agent.Budget{MaxTokens: s.MaxTokens})
+	if instructions := s.Tools.Instructions(); instructions != "" {
+		history = append([]*agent.Message{agent.SystemMessage(instructions)}, history...)
+	}
+	if err := a.Append(history...); err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	c := &completion{
+		ID:      newID(),
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+	if req.Stream {
+		s.stream(r.Context(), w, a, input, c, req.StreamOptions.IncludeUsage)
+		return
+	}
+
+	reply, err := a.Run(r.Context(), input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "completion failed: %v\n", err)
+		status, typ := errorStatus(err)
+		writeError(w, status, typ, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, c.response(reply.Content, a.Meter.Total()))
+}
+
+// stream runs the conversation, sending empty chunks to keep the stream alive
+// while the tools run, then the answer.
+func (s *Server) stream(ctx context.Context, w http.ResponseWriter, a *agent.Agent, input string, c *completion, includeUsage bool) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(c.chunk(map[string]any{"role": "assistant"}, nil))
+
+	type outcome struct {
+		reply *agent.Message
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		reply, err := a.Run(ctx, input)
+		done <- outcome{reply, err}
+	}()
+
+	ticker := time.NewTicker(s.KeepAlive)
+	defer ticker.Stop()
+	var result outcome
+wait:
+	for {
+		select {
+		case <-ticker.C:
+			// Some clients choke on SSE comments: send empty deltas.
+			send(c.chunk(map[string]any{}, nil))
+		case result = <-done:
+			break wait
+		}
+	}
+
+	if result.err != nil {
+		fmt.Fprintf(os.Stderr, "completion failed: %v\n", result.err)
+		_, typ := errorStatus(result.err)
+		send(map[string]any{"error": map[string]any{"message": result.err.Error(), "type": typ}})
+	} else {
+		send(c.chunk(map[string]any{"content": result.reply.Content}, nil))
+		stop := "stop"
+		send(c.chunk(map[string]any{}, &stop))
+		if includeUsage {
+			chunk := c.chunk(nil, nil)
+			chunk["choices"] = []any{}
+			chunk["usage"] = usage(a.Meter.Total())
+			send(chunk)
+		}
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// completion holds the fields common to the responses of a request.
+type completion struct {
+	ID      string
+	Created int64
+	Model   string
+}
+
+func (c *completion) response(content string, u agent.Usage) map[string]any {
+	return map[string]any{
+		"id":      c.ID,
+		"object":  "chat.completion",
+		"created": c.Created,
+		"model":   c.Model,
+		"choices": []any{
+			map[string]any{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			},
+		},
+		"usage": usage(u),
+	}
+}
+
+func (c *completion) chunk(delta map[string]any, finishReason *string) map[string]any {
+	return map[string]any{
+		"id":      c.ID,
+		"object":  "chat.completion.chunk",
+		"created": c.Created,
+		"model":   c.Model,
+		"choices": []any{
+			map[string]any{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			},
+		},
+	}
+}
+
+// usage reports the usage of the whole tool loop.
+func usage(u agent.Usage) map[string]any {
+	return map[string]any{
+		"prompt_tokens":     u.PromptTokens,
+		"completion_tokens": u.CompletionTokens,
+		"total_tokens":      u.Tokens(),
+	}
+}
+
+func (s *Server) models(w http.ResponseWriter, r *http.Request) {
+	models := []map[string]any{}
+	if s.Model != "" {
+		models = append(models, map[string]any{"id": s.Model, "object": "model", "owned_by": "langdag"})
+	} else {
+		page, err := s.Client.Models.List(r.Context())
+		if err != nil {
+			status, typ := errorStatus(err)
+			writeError(w, status, typ, err.Error())
+			return
+		}
+		for _, m := range page.Data {
+			models = append(models, map[string]any{"id": m.ID, "object": "model", "created": m.Created, "owned_by": m.OwnedBy})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+// errorStatus maps an error to a status and an OpenAI error type.
+func errorStatus(err error) (int, string) {
+	var apiErr *openai.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return http.StatusBadGateway, "upstream_error"
+	case errors.Is(err, agent.ErrBudgetExceeded):
+		return http.StatusTooManyRequests, "insufficient_quota"
+	}
+	return http.StatusInternalServerError, "server_error"
+}
+
+func writeError(w http.ResponseWriter, status int, typ, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{"message": message, "type": typ, "code": nil},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write response: %v\n", err)
+	}
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,290 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aluzzardi/langdag/tool"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// upstream is a provider answering with scripted completions, in order.
+type upstream struct {
+	mu       sync.Mutex
+	replies  []map[string]any
+	requests []map[string]any
+}
+
+// newUpstream returns a client of a provider answering with replies.
+func newUpstream(t *testing.T, replies ...map[string]any) (*openai.Client, *upstream) {
+	t.Helper()
+	u := &upstream{replies: replies}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		u.requests = append(u.requests, req)
+		if len(u.replies) == 0 {
+			http.Error(w, `{"error": {"message": "no more replies"}}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u.replies[0])
+		u.replies = u.replies[1:]
+	}))
+	t.Cleanup(srv.Close)
+	return openai.NewClient(
+		option.WithBaseURL(srv.URL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+	), u
+}
+
+// reply returns a completion with an answer or, given calls as
+// name/arguments pairs, tool calls.
+func reply(content string, calls ...string) map[string]any {
+	message := map[string]any{"role": "assistant", "content": content}
+	finish := "stop"
+	if len(calls) > 0 {
+		toolCalls := []any{}
+		for i := 0; i < len(calls); i += 2 {
+			toolCalls = append(toolCalls, map[string]any{
+				"id":       fmt.Sprintf("call_%d", i/2),
+				"type":     "function",
+				"function": map[string]any{"name": calls[i], "arguments": calls[i+1]},
+			})
+		}
+		message["tool_calls"] = toolCalls
+		finish = "tool_calls"
+	}
+	return map[string]any{
+		"id":      "chatcmpl-upstream",
+		"object":  "chat.completion",
+		"model":   "gpt-4o",
+		"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": finish}},
+		"usage":   map[string]any{"prompt_tokens": 100, "completion_tokens": 10, "total_tokens": 110},
+	}
+}
+
+type weatherArgs struct {
+	City string `json:"city"`
+}
+
+func weather() tool.Tool {
+	return tool.Func("weather", "Get the weather of a city.", func(_ context.Context, args weatherArgs) (string, error) {
+		return "sunny in " + args.City, nil
+	})
+}
+
+// post sends a chat completion request to the proxy.
+func post(t *testing.T, s *Server, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	return w
+}
+
+// checkRoundTrip checks the upstream got the result of the tool call.
+func checkRoundTrip(t *testing.T, u *upstream) {
+	t.Helper()
+	if len(u.requests) != 2 {
+		t.Fatalf("upstream got %d requests, want 2", len(u.requests))
+	}
+	if tools, _ := u.requests[0]["tools"].([]any); len(tools) != 1 {
+		t.Errorf("upstream got tools %v", u.requests[0]["tools"])
+	}
+	msgs, _ := u.requests[1]["messages"].([]any)
+	last, _ := msgs[len(msgs)-1].(map[string]any)
+	if last["role"] != "tool" || !strings.Contains(fmt.Sprint(last["content"]), "sunny in Paris") {
+		t.Errorf("upstream got %v, want the tool result", last)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	client, u := newUpstream(t,
+		reply("", "weather", `{"city": "Paris"}`),
+		reply("It's sunny."),
+	)
+	s := New(client, tool.Tools{weather()})
+
+	w := post(t, s, `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Weather in Paris?"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var res struct {
+		Object  string `json:"object"`
+		Choices []struct {
+			Message      map[string]any `json:"message"`
+			FinishReason string         `json:"finish_reason"`
+		} `json:"choices"`
+		Usage map[string]int `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Object != "chat.completion" || len(res.Choices) != 1 || res.Choices[0].Message["content"] != "It's sunny." || res.Choices[0].FinishReason != "stop" {
+		t.Errorf("unexpected response %s", w.Body)
+	}
+	if res.Usage["total_tokens"] != 220 {
+		t.Errorf("usage = %v, want the whole tool loop", res.Usage)
+	}
+	checkRoundTrip(t, u)
+}
+
+func TestCompletionParameters(t *testing.T) {
+	client, u := newUpstream(t,
+		reply("", "weather", `{"city": "Paris"}`),
+		reply("It's sunny."),
+	)
+	s := New(client, tool.Tools{weather()})
+
+	w := post(t, s, `{"model": "gpt-4o", "temperature": 0.2, "max_tokens": 50, "stop": ["\n"], "n": 1, "top_p": null, "messages": [{"role": "user", "content": "Weather in Paris?"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	for i, req := range u.requests {
+		if req["temperature"] != 0.2 || req["max_tokens"] != float64(50) || fmt.Sprint(req["stop"]) != "[\n]" {
+			t.Errorf("request %d: parameters were not forwarded: %v", i, req)
+		}
+		if _, ok := req["top_p"]; ok {
+			t.Errorf("request %d: null parameter was forwarded", i)
+		}
+	}
+}
+
+func TestCompletionHistory(t *testing.T) {
+	client, u := newUpstream(t, reply("Still sunny."))
+	s := New(client, tool.Tools{weather()})
+
+	w := post(t, s, `{"model": "gpt-4o", "messages": [
+		{"role": "user", "content": "Weather in Paris?"},
+		{"role": "assistant", "content": null, "tool_calls": [{"id": "call_0", "type": "function", "function": {"name": "weather", "arguments": "{\"city\": \"Paris\"}"}}]},
+		{"role": "tool", "tool_call_id": "call_0", "content": "sunny in Paris"},
+		{"role": "assistant", "content": "It's sunny."},
+		{"role": "user", "content": "And now?"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	msgs, _ := u.requests[0]["messages"].([]any)
+	call, _ := msgs[1].(map[string]any)
+	calls, _ := call["tool_calls"].([]any)
+	if len(calls) != 1 || !strings.Contains(fmt.Sprint(calls[0]), "weather") {
+		t.Errorf("upstream got %v, want the tool calls of the client", call)
+	}
+}
+
+// events returns the data of the events of a stream.
+func events(t *testing.T, body string) []string {
+	t.Helper()
+	data := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if d, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			data = append(data, d)
+		}
+	}
+	return data
+}
+
+func TestCompletionStream(t *testing.T) {
+	client, u := newUpstream(t,
+		reply("", "weather", `{"city": "Paris"}`),
+		reply("It's sunny."),
+	)
+	s := New(client, tool.Tools{weather()})
+
+	w := post(t, s, `{"model": "gpt-4o", "stream": true, "stream_options": {"include_usage": true}, "messages": [{"role": "user", "content": "Weather in Paris?"}]}`)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	data := events(t, w.Body.String())
+	if len(data) == 0 || data[len(data)-1] != "[DONE]" {
+		t.Fatalf("stream does not end with [DONE]: %v", data)
+	}
+
+	content, finish := "", ""
+	var usage map[string]any
+	for _, d := range data[:len(data)-1] {
+		var chunk struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Delta        map[string]any `json:"delta"`
+				FinishReason *string        `json:"finish_reason"`
+			} `json:"choices"`
+			Usage map[string]any `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(d), &chunk); err != nil {
+			t.Fatalf("invalid chunk %s: %v", d, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("unexpected chunk %s", d)
+		}
+		for _, c := range chunk.Choices {
+			if s, ok := c.Delta["content"].(string); ok {
+				content += s
+			}
+			if c.FinishReason != nil {
+				finish = *c.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if content != "It's sunny." || finish != "stop" {
+		t.Errorf("content %q, finish reason %q", content, finish)
+	}
+	if usage["total_tokens"] != float64(220) {
+		t.Errorf("usage = %v", usage)
+	}
+	checkRoundTrip(t, u)
+}
+
+func TestCompletionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		auth   string
+		body   string
+		status int
+	}{
+		{"unauthorized", "secret", "Bearer wrong", `{"model": "gpt-4o", "messages": [{"role": "user", "content": "hi"}]}`, http.StatusUnauthorized},
+		{"invalid JSON", "", "", `{`, http.StatusBadRequest},
+		{"client tools", "", "", `{"model": "gpt-4o", "messages": [{"role": "user", "content": "hi"}], "tools": [{"type": "function"}]}`, http.StatusBadRequest},
+		{"no messages", "", "", `{"model": "gpt-4o", "messages": []}`, http.StatusBadRequest},
+		{"last message", "", "", `{"model": "gpt-4o", "messages": [{"role": "assistant", "content": "hi"}]}`, http.StatusBadRequest},
+		{"images", "", "", `{"model": "gpt-4o", "messages": [{"role": "user", "content": [{"type": "image_url"}]}]}`, http.StatusBadRequest},
+		{"no model", "", "", `{"messages": [{"role": "user", "content": "hi"}]}`, http.StatusBadRequest},
+		{"unsupported parameter", "", "", `{"model": "gpt-4o", "logprobs": true, "messages": [{"role": "user", "content": "hi"}]}`, http.StatusBadRequest},
+		{"several choices", "", "", `{"model": "gpt-4o", "n": 2, "messages": [{"role": "user", "content": "hi"}]}`, http.StatusBadRequest},
+		{"unknown tool call", "", "", `{"model": "gpt-4o", "messages": [{"role": "tool", "tool_call_id": "call_0", "content": "sunny"}, {"role": "user", "content": "hi"}]}`, http.StatusBadRequest},
+		{"tool call without name", "", "", `{"model": "gpt-4o", "messages": [{"role": "assistant", "tool_calls": [{"id": "call_0", "type": "function"}]}, {"role": "user", "content": "hi"}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, u := newUpstream(t)
+			s := New(client, tool.Tools{weather()})
+			s.Token = tt.token
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			r.Header.Set("Authorization", tt.auth)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if len(u.requests) != 0 {
+				t.Errorf("request was sent upstream")
+			}
+		})
+	}
+}
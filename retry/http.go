@@ -0,0 +1,177 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport retries HTTP requests that fail with a network error or a
+// retryable status code, honoring the delays requested by rate limit headers.
+//
+// Policy.Timeout bounds every attempt, up to the end of its response body.
+type Transport struct {
+	Policy Policy
+
+	// Base executes the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// HTTPClient returns a client retrying requests according to p, e.g. to
+// configure a model API client with option.WithHTTPClient. Clients retrying
+// on their own should have their retries disabled.
+func HTTPClient(base http.RoundTripper, p Policy) *http.Client {
+	return &http.Client{Transport: &Transport{Policy: p, Base: base}}
+}
+
+// StatusError is the outcome of an attempt that got a retryable status code.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	After      time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %s", e.Status)
+}
+
+func (e *StatusError) RetryAfter() time.Duration {
+	return e.After
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	var body []byte
+	if req.Body != nil && req.GetBody == nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The timeout applies until the body is closed: attempts are timed here
+	// rather than by Policy.Do.
+	p := t.Policy
+	timeout := p.Timeout
+	p.Timeout = 0
+	retryable := p.withDefaults().Retryable
+	p.Retryable = func(err error) bool {
+		var status *StatusError
+		return errors.As(err, &status) || retryable(err)
+	}
+
+	var (
+		resp *http.Response
+		last *http.Response
+	)
+	err := p.Do(req.Context(), req.Method+" "+req.URL.String(), func(ctx context.Context) error {
+		cancel := context.CancelFunc(func() {})
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+
+		r := req.Clone(ctx)
+		switch {
+		case req.GetBody != nil:
+			b, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			r.Body = b
+		case body != nil:
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		res, err := base.RoundTrip(r)
+		if err != nil {
+			cancel()
+			if timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return &TimeoutError{Timeout: timeout, Err: err}
+			}
+			return err
+		}
+
+		if retryableStatus(res.StatusCode) {
+			// Keep the response around: it's handed back to the caller if
+			// there are no retries left.
+			data, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			cancel()
+			if err != nil {
+				return err
+			}
+			res.Body = io.NopCloser(bytes.NewReader(data))
+			last = res
+			return &StatusError{StatusCode: res.StatusCode, Status: res.Status, After: rateLimitDelay(res.Header)}
+		}
+
+		res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+		resp = res
+		return nil
+	})
+	if resp != nil {
+		return resp, nil
+	}
+	var status *StatusError
+	if errors.As(err, &status) && last != nil {
+		return last, nil
+	}
+	return nil, err
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout ||
+		code == http.StatusConflict ||
+		code == http.StatusTooManyRequests ||
+		code >= http.StatusInternalServerError
+}
+
+// rateLimitDelay returns how long the server asked to wait before retrying.
+func rateLimitDelay(h http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if s, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(s * float64(time.Second))
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return time.Until(t)
+		}
+	}
+
+	// OpenAI style limits: wait for the exhausted ones to reset.
+	var delay time.Duration
+	for _, limit := range []string{"requests", "tokens"} {
+		if h.Get("X-Ratelimit-Remaining-"+limit) != "0" {
+			continue
+		}
+		if d, err := time.ParseDuration(h.Get("X-Ratelimit-Reset-" + limit)); err == nil && d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,128 @@
+package retry
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    time.Duration
+	}{
+		{name: "none", want: 0},
+		{name: "retry-after-ms", headers: map[string]string{"Retry-After-Ms": "1500"}, want: 1500 * time.Millisecond},
+		{name: "retry-after seconds", headers: map[string]string{"Retry-After": "3"}, want: 3 * time.Second},
+		{name: "retry-after fraction", headers: map[string]string{"Retry-After": "0.5"}, want: 500 * time.Millisecond},
+		{name: "retry-after-ms first", headers: map[string]string{"Retry-After-Ms": "200", "Retry-After": "3"}, want: 200 * time.Millisecond},
+		{name: "invalid retry-after", headers: map[string]string{"Retry-After": "soon"}, want: 0},
+		{
+			name: "exhausted requests",
+			headers: map[string]string{
+				"X-Ratelimit-Remaining-Requests": "0",
+				"X-Ratelimit-Reset-Requests":     "1s",
+				"X-Ratelimit-Remaining-Tokens":   "100",
+				"X-Ratelimit-Reset-Tokens":       "6m0s",
+			},
+			want: time.Second,
+		},
+		{
+			name: "longest exhausted limit",
+			headers: map[string]string{
+				"X-Ratelimit-Remaining-Requests": "0",
+				"X-Ratelimit-Reset-Requests":     "1s",
+				"X-Ratelimit-Remaining-Tokens":   "0",
+				"X-Ratelimit-Reset-Tokens":       "20ms",
+			},
+			want: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			if got := rateLimitDelay(h); got != tt.want {
+				t.Errorf("rateLimitDelay() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitDelayDate(t *testing.T) {
+	h := http.Header{}
+	h.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if got := rateLimitDelay(h); got < 58*time.Second || got > time.Minute {
+		t.Errorf("rateLimitDelay() = %s, want about a minute", got)
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt got body %q", body)
+		}
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After-Ms", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client := HTTPClient(nil, Policy{MaxRetries: 3, InitialDelay: time.Millisecond})
+	resp, err := client.Post(srv.URL, "text/plain", stringReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 3 {
+		t.Errorf("got %s after %d attempts", resp.Status, attempts.Load())
+	}
+}
+
+func TestTransportGivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := HTTPClient(nil, Policy{MaxRetries: 1, InitialDelay: time.Millisecond})
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusServiceUnavailable || string(body) != "down\n" || attempts.Load() != 2 {
+		t.Errorf("got %s %q after %d attempts", resp.Status, body, attempts.Load())
+	}
+}
+
+func stringReader(s string) io.Reader {
+	return &onceReader{s: s}
+}
+
+// onceReader hides the type of the body, so that requests have no GetBody
+// and the transport has to buffer it.
+type onceReader struct{ s string }
+
+func (r *onceReader) Read(p []byte) (int, error) {
+	if r.s == "" {
+		return 0, io.EOF
+	}
+	n := copy(p, r.s)
+	r.s = r.s[n:]
+	return n, nil
+}
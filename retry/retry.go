@@ -0,0 +1,170 @@
+// Package retry implements timeout, retry and backoff policies for tool and
+// model calls.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Policy controls how an operation is retried. Zero values fall back to the
+// values of DefaultPolicy, except MaxRetries.
+type Policy struct {
+	// Timeout bounds every attempt. Zero means no timeout.
+	Timeout time.Duration
+
+	// MaxRetries is the number of attempts made after the first one.
+	MaxRetries int
+
+	// InitialDelay is the delay before the first retry. It is multiplied by
+	// Multiplier after every attempt, up to MaxDelay.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+
+	// Retryable reports whether an error is worth retrying. Defaults to
+	// IsTransient.
+	Retryable func(error) bool
+}
+
+var DefaultPolicy = Policy{
+	MaxRetries:   3,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     time.Minute,
+	Multiplier:   2,
+}
+
+// Never disables retries.
+var Never = Policy{}
+
+func (p Policy) withDefaults() Policy {
+	if p.InitialDelay == 0 {
+		p.InitialDelay = DefaultPolicy.InitialDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = DefaultPolicy.MaxDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultPolicy.Multiplier
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsTransient
+	}
+	return p
+}
+
+// Delay returns the backoff before retry number attempt (starting at 1),
+// with up to 20% of jitter.
+func (p Policy) Delay(attempt int) time.Duration {
+	p = p.withDefaults()
+	d := float64(p.InitialDelay)
+	for i := 1; i < attempt && d < float64(p.MaxDelay); i++ {
+		d *= p.Multiplier
+	}
+	d = min(d, float64(p.MaxDelay))
+	d -= d * 0.2 * rand.Float64()
+	return time.Duration(d)
+}
+
+// AfterError is implemented by errors that know how long to wait before
+// retrying, e.g. rate limit responses.
+type AfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// Do calls fn until it succeeds, fails with an error that isn't retryable,
+// or runs out of retries. The last error is returned.
+func (p Policy) Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	p = p.withDefaults()
+
+	for attempt := 0; ; attempt++ {
+		err := p.attempt(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || attempt >= p.MaxRetries || !p.Retryable(err) {
+			if attempt > 0 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+
+		delay := p.Delay(attempt + 1)
+		var after AfterError
+		if errors.As(err, &after) && after.RetryAfter() > delay {
+			delay = min(after.RetryAfter(), p.MaxDelay)
+		}
+		fmt.Fprintf(os.Stderr, "retrying %s in %s (attempt %d/%d): %v\n", name, delay.Round(time.Millisecond), attempt+2, p.MaxRetries+1, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.Timeout == 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{Timeout: p.Timeout, Err: err}
+	}
+	return err
+}
+
+// TimeoutError is returned when an attempt exceeds the timeout of its policy.
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s: %v", e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// transientMessages match errors reported as text by the engine or by the
+// commands modules run: registry pulls, flaky upstream APIs, network errors.
+var transientMessages = regexp.MustCompile(`(?i)` +
+	`\b(http|status|code)\W{0,3}(429|500|502|503|504)\b|bad gateway|service unavailable|gateway time-?out|internal server error|` +
+	`too many requests|toomanyrequests|rate limit|` +
+	`connection reset|connection refused|broken pipe|unexpected eof|i/o timeout|tls handshake timeout|` +
+	`temporary failure|no such host|network is unreachable|` +
+	`failed to resolve source metadata|failed to do request|failed to copy`)
+
+// IsTransient reports whether err looks like a temporary failure.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var timeout *TimeoutError
+	if errors.As(err, &timeout) {
+		return true
+	}
+	var after AfterError
+	if errors.As(err, &after) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return transientMessages.MatchString(err.Error())
+}
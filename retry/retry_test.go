@@ -0,0 +1,85 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	p := Policy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		got := p.Delay(tt.attempt)
+		// Up to 20% of jitter.
+		if got > tt.want || got < tt.want*8/10 {
+			t.Errorf("Delay(%d) = %s, want %s minus jitter", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{errors.New("exit code: 1"), false},
+		{errors.New("failed to resolve source metadata for docker.io/library/alpine"), true},
+		{errors.New("received status 503 from registry"), true},
+		{errors.New("429 Too Many Requests"), true},
+		{errors.New("read tcp: connection reset by peer"), true},
+		{&TimeoutError{Timeout: time.Second, Err: context.DeadlineExceeded}, true},
+		{fmt.Errorf("call: %w", &StatusError{StatusCode: 429}), true},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{MaxRetries: 2, InitialDelay: time.Millisecond}
+	attempts := 0
+	err := p.Do(context.Background(), "test", func(context.Context) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	if err == nil || attempts != 3 {
+		t.Errorf("Do() = %v after %d attempts, want an error after 3", err, attempts)
+	}
+
+	attempts = 0
+	err = p.Do(context.Background(), "test", func(context.Context) error {
+		attempts++
+		return errors.New("exit code: 1")
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("permanent error retried: %d attempts", attempts)
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	p := Policy{Timeout: 10 * time.Millisecond}
+	err := p.Do(context.Background(), "test", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Timeout != p.Timeout {
+		t.Errorf("Do() = %v, want a TimeoutError", err)
+	}
+}
@@ -0,0 +1,184 @@
+package tool
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dagger.io/dagger"
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Annotations are hints about the behavior of a tool, on top of its safety,
+// for MCP clients to decide when to ask users for confirmation.
+type Annotations struct {
+	// Title is a human readable name of the tool.
+	Title string `json:"title,omitempty"`
+
+	// Idempotent tools have no additional effect when called again with the
+	// same arguments. Read-only tools are idempotent.
+	Idempotent *bool `json:"idempotent,omitempty"`
+
+	// OpenWorld tools interact with external entities, such as GitHub,
+	// rather than a closed domain. Unset means they do.
+	OpenWorld *bool `json:"openWorld,omitempty"`
+}
+
+// merge returns a with the fields set in other overridden.
+func (a Annotations) merge(other Annotations) Annotations {
+	if other.Title != "" {
+		a.Title = other.Title
+	}
+	if other.Idempotent != nil {
+		a.Idempotent = other.Idempotent
+	}
+	if other.OpenWorld != nil {
+		a.OpenWorld = other.OpenWorld
+	}
+	return a
+}
+
+// annotationPragma declares an annotation of a function in its description,
+// on a line of its own like the safety pragma: "title: Close an issue",
+// "idempotent: true" or "open-world: false".
+var annotationPragma = regexp.MustCompile(`(?im)^\s*(title|idempotent|open-world):\s*(.+?)\s*$`)
+
+// deriveAnnotations reads the annotation pragmas of a description.
+func deriveAnnotations(description string) Annotations {
+	a := Annotations{}
+	for _, m := range annotationPragma.FindAllStringSubmatch(description, -1) {
+		switch strings.ToLower(m[1]) {
+		case "title":
+			a.Title = m[2]
+		case "idempotent":
+			if v, err := strconv.ParseBool(m[2]); err == nil {
+				a.Idempotent = &v
+			}
+		case "open-world":
+			if v, err := strconv.ParseBool(m[2]); err == nil {
+				a.OpenWorld = &v
+			}
+		}
+	}
+	return a
+}
+
+// ParseAnnotations parses a comma separated list of annotations, named like
+// their pragmas: "title=Close an issue,idempotent=true,open-world=false".
+func ParseAnnotations(s string) (Annotations, error) {
+	a := Annotations{}
+	for _, field := range strings.Split(s, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return a, fmt.Errorf("invalid annotation %q (expected name=value)", field)
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "title":
+			a.Title = value
+		case "idempotent", "open-world":
+			v, err := strconv.ParseBool(value)
+			if err != nil {
+				return a, fmt.Errorf("invalid annotation %q: expected true or false", field)
+			}
+			if key == "idempotent" {
+				a.Idempotent = &v
+			} else {
+				a.OpenWorld = &v
+			}
+		default:
+			return a, fmt.Errorf("unknown annotation %q (expected title, idempotent or open-world)", key)
+		}
+	}
+	return a, nil
+}
+
+// WithAnnotations overrides the annotations declared in the module metadata.
+// Like with WithSafety, the function is named either after the tool or after
+// the module function. Only the fields set are overridden.
+func WithAnnotations(function string, annotations Annotations) LoadOption {
+	return func(o *loadOptions) {
+		o.annotations[function] = o.annotations[function].merge(annotations)
+	}
+}
+
+// AnnotationsOf returns the annotations of a tool.
+func AnnotationsOf(t Tool) Annotations {
+	if a, ok := t.(interface{ Annotations() Annotations }); ok {
+		return a.Annotations()
+	}
+	return Annotations{}
+}
+
+// mcpAnnotations returns the MCP annotations of a tool, the read-only and
+// destructive hints coming from its safety.
+func mcpAnnotations(t Tool) mcp.ToolAnnotation {
+	a := AnnotationsOf(t)
+	safety := SafetyOf(t)
+
+	readOnly := safety == ReadOnly
+	destructive := safety == Destructive
+	annotations := mcp.ToolAnnotation{
+		Title:           a.Title,
+		ReadOnlyHint:    &readOnly,
+		DestructiveHint: &destructive,
+		IdempotentHint:  a.Idempotent,
+		OpenWorldHint:   a.OpenWorld,
+	}
+	if readOnly && annotations.IdempotentHint == nil {
+		annotations.IdempotentHint = &readOnly
+	}
+	return annotations
+}
+
+// OutputSchema returns the JSON schema of the structured result of the tool,
+// an object holding the value returned by the function: {"result": ...}.
+//
+// Functions returning objects or nothing have no output schema: objects are
+// returned as content, such as the contents of a file.
+func (t *ModuleTool) OutputSchema() *jsonschema.Schema {
+	if t.fn.ReturnType == nil {
+		return nil
+	}
+	result := valueSchema(t.fn.ReturnType)
+	if result == nil {
+		return nil
+	}
+	result.Description = returnType(t.fn.ReturnType)
+	return &jsonschema.Schema{
+		Type:       "object",
+		Properties: map[string]*jsonschema.Schema{"result": result},
+		Required:   []string{"result"},
+	}
+}
+
+// valueSchema returns the schema of values of a type, or nil for objects and
+// void.
+func valueSchema(t *modTypeDef) *jsonschema.Schema {
+	switch t.Kind {
+	case dagger.TypeDefKindStringKind, dagger.TypeDefKindScalarKind:
+		return &jsonschema.Schema{Type: "string"}
+	case dagger.TypeDefKindIntegerKind:
+		return &jsonschema.Schema{Type: "integer"}
+	case dagger.TypeDefKindBooleanKind:
+		return &jsonschema.Schema{Type: "boolean"}
+	case dagger.TypeDefKindEnumKind:
+		schema := &jsonschema.Schema{Type: "string"}
+		for _, v := range t.AsEnum.ValueNames() {
+			schema.Enum = append(schema.Enum, v)
+		}
+		return schema
+	case dagger.TypeDefKindListKind:
+		items := valueSchema(t.AsList.ElementTypeDef)
+		if items == nil {
+			return nil
+		}
+		return &jsonschema.Schema{Type: "array", Items: items}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		spec    string
+		want    Annotations
+		wantErr bool
+	}{
+		{spec: "", want: Annotations{}},
+		{spec: "title=Close an issue", want: Annotations{Title: "Close an issue"}},
+		{spec: "idempotent=true, open-world=false", want: Annotations{Idempotent: &yes, OpenWorld: &no}},
+		{spec: "Title=Scan,idempotent=1", want: Annotations{Title: "Scan", Idempotent: &yes}},
+		{spec: "idempotent", wantErr: true},
+		{spec: "idempotent=maybe", wantErr: true},
+		{spec: "read-only=true", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAnnotations(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAnnotations(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAnnotations(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestWithAnnotations(t *testing.T) {
+	yes := true
+	o := &loadOptions{annotations: map[string]Annotations{}}
+	WithAnnotations("issue-close", Annotations{Title: "Close an issue"})(o)
+	WithAnnotations("issue-close", Annotations{Idempotent: &yes})(o)
+	want := Annotations{Title: "Close an issue", Idempotent: &yes}
+	if got := o.annotations["issue-close"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("annotations = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,328 @@
+package tool
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"dagger.io/dagger"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var (
+	// MaxTextContent is the number of bytes of text files returned as
+	// content. Longer files are truncated.
+	MaxTextContent = 256 << 10
+
+	// MaxBlobContent is the size of the largest image or binary file returned
+	// as content.
+	MaxBlobContent = 4 << 20
+
+	// MaxDirectoryEntries is the number of entries of a directory linked to.
+	MaxDirectoryEntries = 100
+)
+
+// callMCP runs the tool like Call, rendering the result as MCP content and,
+// if the tool has an output schema, as structured content.
+//
+// Files are returned by content: text, image or embedded resource.
+// Directories are listed, with links to their entries which can be read with
+// MCPResultHandler. Containers are summarized. Other results are returned as
+// JSON text.
+func (t *ModuleTool) callMCP(ctx context.Context, arguments string) (*mcp.CallToolResult, error) {
+	out, err := t.Call(ctx, arguments)
+	if err != nil {
+		return nil, err
+	}
+	content, err := t.content(ctx, out)
+	if err != nil {
+		return nil, err
+	}
+	res := &mcp.CallToolResult{Content: content}
+	if t.OutputSchema() != nil {
+		res.StructuredContent = map[string]any{"result": Result(t, out)}
+	}
+	return res, nil
+}
+
+// content renders the output of a call.
+func (t *ModuleTool) content(ctx context.Context, out string) ([]mcp.Content, error) {
+	text := []mcp.Content{mcp.NewTextContent(out)}
+
+	rt := t.fn.ReturnType
+	if t.dag == nil || rt == nil || rt.Kind != dagger.TypeDefKindObjectKind {
+		return text, nil
+	}
+	var data map[string]map[string]struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(out), &data); err != nil {
+		return text, nil
+	}
+	id := data[t.mod.Name][t.fn.Name].ID
+	if id == "" {
+		return text, nil
+	}
+
+	switch rt.AsObject.Name {
+	case "File":
+		return fileContent(ctx, t.dag.LoadFileFromID(dagger.FileID(id)))
+	case "Directory":
+		return directoryContent(ctx, t.dag.LoadDirectoryFromID(dagger.DirectoryID(id)))
+	case "Container":
+		return containerContent(ctx, t.dag.LoadContainerFromID(dagger.ContainerID(id)))
+	}
+	return text, nil
+}
+
+func fileContent(ctx context.Context, file *dagger.File) ([]mcp.Content, error) {
+	name, err := file.Name(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uri := results.add(file) + "/" + name
+	reportProgress(ctx, "reading %s", name)
+	contents, err := readFile(ctx, file, name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := contents.(type) {
+	case mcp.TextResourceContents:
+		return []mcp.Content{mcp.NewTextContent(c.Text)}, nil
+	case mcp.BlobResourceContents:
+		switch c.MIMEType {
+		case "image/png", "image/jpeg", "image/gif", "image/webp":
+			return []mcp.Content{mcp.NewImageContent(c.Blob, c.MIMEType)}, nil
+		}
+		c.URI = uri
+		return []mcp.Content{mcp.NewEmbeddedResource(c)}, nil
+	}
+	return []mcp.Content{mcp.NewTextContent(fmt.Sprint(contents))}, nil
+}
+
+// readFile reads a file as text if it is, and as a blob otherwise, within the
+// size limits.
+func readFile(ctx context.Context, file *dagger.File, name string) (mcp.ResourceContents, error) {
+	size, err := file.Size(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Text over MaxTextContent is truncated rather than rejected.
+	if limit := max(MaxBlobContent, MaxTextContent); size > limit {
+		return mcp.TextResourceContents{
+			MIMEType: "text/plain",
+			Text:     fmt.Sprintf("%s is %d bytes, over the limit of %d bytes: it can't be returned.", name, size, limit),
+		}, nil
+	}
+
+	// Contents are strings: export the file to read binary data as is.
+	dir, err := os.MkdirTemp("", "langdag-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, filepath.Base(name))
+	if _, err := file.Export(ctx, local); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if utf8.Valid(data) && !strings.HasPrefix(mimeType, "image/") {
+		text := string(data)
+		if len(text) > MaxTextContent {
+			text = strings.ToValidUTF8(text[:MaxTextContent], "") + fmt.Sprintf("\n[... truncated, %d bytes total]", len(data))
+		}
+		return mcp.TextResourceContents{MIMEType: mimeType, Text: text}, nil
+	}
+	if len(data) > MaxBlobContent {
+		return mcp.TextResourceContents{
+			MIMEType: "text/plain",
+			Text:     fmt.Sprintf("%s is %d bytes, over the limit of %d bytes: it can't be returned.", name, len(data), MaxBlobContent),
+		}, nil
+	}
+	return mcp.BlobResourceContents{MIMEType: mimeType, Blob: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+func directoryContent(ctx context.Context, dir *dagger.Directory) ([]mcp.Content, error) {
+	reportProgress(ctx, "listing directory")
+	entries, err := dir.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uri := results.add(dir)
+
+	content := []mcp.Content{mcp.NewTextContent(listing(entries))}
+	for i, entry := range entries {
+		if i == MaxDirectoryEntries {
+			break
+		}
+		content = append(content, mcp.NewResourceLink(uri+"/"+entry, entry, "", mime.TypeByExtension(path.Ext(entry))))
+	}
+	return content, nil
+}
+
+func listing(entries []string) string {
+	if len(entries) == 0 {
+		return "Empty directory."
+	}
+	return fmt.Sprintf("Directory with %d entries:\n%s", len(entries), strings.Join(entries, "\n"))
+}
+
+func containerContent(ctx context.Context, ctr *dagger.Container) ([]mcp.Content, error) {
+	reportProgress(ctx, "inspecting container")
+	sb := new(strings.Builder)
+	sb.WriteString("Container\n")
+	// Only containers pulled from a registry have an image reference.
+	if ref, err := ctr.ImageRef(ctx); err == nil && ref != "" {
+		fmt.Fprintf(sb, "image: %s\n", ref)
+	}
+	platform, err := ctr.Platform(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(sb, "platform: %s\n", platform)
+	if entrypoint, err := ctr.Entrypoint(ctx); err == nil && len(entrypoint) > 0 {
+		fmt.Fprintf(sb, "entrypoint: %s\n", strings.Join(entrypoint, " "))
+	}
+	if args, err := ctr.DefaultArgs(ctx); err == nil && len(args) > 0 {
+		fmt.Fprintf(sb, "default args: %s\n", strings.Join(args, " "))
+	}
+	if workdir, err := ctr.Workdir(ctx); err == nil && workdir != "" {
+		fmt.Fprintf(sb, "workdir: %s\n", workdir)
+	}
+	if user, err := ctr.User(ctx); err == nil && user != "" {
+		fmt.Fprintf(sb, "user: %s\n", user)
+	}
+	env, err := ctr.EnvVariables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(env) > 0 {
+		sb.WriteString("env:\n")
+		for _, v := range env {
+			name, err := v.Name(ctx)
+			if err != nil {
+				return nil, err
+			}
+			value, err := v.Value(ctx)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(sb, "  %s=%s\n", name, value)
+		}
+	}
+	return []mcp.Content{mcp.NewTextContent(sb.String())}, nil
+}
+
+// maxResults is the number of results kept for MCPResultHandler.
+const maxResults = 1000
+
+// resultStore keeps the files and directories returned by tools so that
+// clients can read them, and the entries of directories, later on.
+type resultStore struct {
+	mu      sync.Mutex
+	next    int
+	results map[int]any
+}
+
+var results = &resultStore{results: map[int]any{}}
+
+// add stores a result and returns its URI.
+func (s *resultStore) add(result any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	s.results[s.next] = result
+	delete(s.results, s.next-maxResults)
+	return "dagger://results/" + strconv.Itoa(s.next)
+}
+
+func (s *resultStore) get(id int) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results[id]
+}
+
+// MCPResultTemplate is the template of the URIs of files and directories
+// returned by tools.
+func MCPResultTemplate() mcp.ResourceTemplate {
+	return mcp.NewResourceTemplate("dagger://results/{result}/{+path}", "Tool results",
+		mcp.WithTemplateDescription("Files and directory entries returned by tools"),
+	)
+}
+
+// MCPResultHandler returns an MCP server handler reading the files and
+// directory entries returned by tools.
+func MCPResultHandler() server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		uri := request.Params.URI
+		rest, ok := strings.CutPrefix(uri, "dagger://results/")
+		if !ok {
+			return nil, fmt.Errorf("not a result: %s", uri)
+		}
+		idText, name, _ := strings.Cut(rest, "/")
+		id, err := strconv.Atoi(idText)
+		if err != nil {
+			return nil, fmt.Errorf("not a result: %s", uri)
+		}
+
+		var contents mcp.ResourceContents
+		switch result := results.get(id).(type) {
+		case *dagger.File:
+			contents, err = readFile(ctx, result, name)
+		case *dagger.Directory:
+			contents, err = readEntry(ctx, result, name)
+		default:
+			return nil, fmt.Errorf("unknown or expired result: %s", uri)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch c := contents.(type) {
+		case mcp.TextResourceContents:
+			c.URI = uri
+			contents = c
+		case mcp.BlobResourceContents:
+			c.URI = uri
+			contents = c
+		}
+		return []mcp.ResourceContents{contents}, nil
+	}
+}
+
+// readEntry reads a file of dir, or lists it if it's a directory.
+func readEntry(ctx context.Context, dir *dagger.Directory, name string) (mcp.ResourceContents, error) {
+	name = strings.TrimSuffix(name, "/")
+	if name != "" {
+		file := dir.File(name)
+		if _, err := file.Size(ctx); err == nil {
+			return readFile(ctx, file, name)
+		}
+		dir = dir.Directory(name)
+	}
+	entries, err := dir.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.TextResourceContents{MIMEType: "text/plain", Text: listing(entries)}, nil
+}
@@ -0,0 +1,166 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"dagger.io/dagger"
+	"github.com/aluzzardi/langdag/jsonschema"
+)
+
+// Descriptions configures how module functions are described to the model.
+type Descriptions struct {
+	// Long includes the whole documentation of functions rather than its
+	// first line.
+	Long bool `json:"long,omitempty"`
+
+	// Arguments documents the defaults and possible values of arguments.
+	Arguments bool `json:"arguments,omitempty"`
+
+	// Returns mentions the type functions return.
+	Returns bool `json:"returns,omitempty"`
+
+	// Module prepends the description of the module to the description of
+	// every tool. Tools.Instructions describes each module once instead,
+	// for the system prompt.
+	Module bool `json:"module,omitempty"`
+
+	// MaxLength is the number of characters the description of a tool, and
+	// the documentation of each of its arguments, is cut to. Zero means no
+	// limit.
+	MaxLength int `json:"maxLength,omitempty"`
+}
+
+// DefaultDescriptions is how module functions are described unless
+// configured otherwise with WithDescriptions.
+var DefaultDescriptions = Descriptions{
+	Long:      true,
+	Arguments: true,
+	Returns:   true,
+	MaxLength: 1024,
+}
+
+// WithDescriptions configures how module functions are described.
+func WithDescriptions(d Descriptions) LoadOption {
+	return func(o *loadOptions) {
+		o.descriptions = d
+	}
+}
+
+func (d Descriptions) function(mod *moduleDef, fn *modFunction) string {
+	parts := []string{}
+	if d.Module && mod.Description != "" {
+		parts = append(parts, mod.Description)
+	}
+	if d.Long {
+		parts = append(parts, strings.TrimSpace(fn.Description))
+	} else {
+		parts = append(parts, fn.Short())
+	}
+	if d.Returns && fn.ReturnType != nil {
+		if returns := returnType(fn.ReturnType); returns != "" {
+			parts = append(parts, "Returns: "+returns)
+		}
+	}
+	return truncate(strings.Join(parts, "\n\n"), d.MaxLength)
+}
+
+func (d Descriptions) argument(arg *modFunctionArg) string {
+	if !d.Arguments {
+		return truncate(arg.Description, d.MaxLength)
+	}
+	return truncate(arg.Long(), d.MaxLength)
+}
+
+// returnType describes a return type. Objects are described by the first
+// line of their documentation: the model only sees their ID.
+func returnType(t *modTypeDef) string {
+	switch t.Kind {
+	case dagger.TypeDefKindVoidKind:
+		return ""
+	case dagger.TypeDefKindObjectKind, dagger.TypeDefKindInterfaceKind, dagger.TypeDefKindListKind:
+		return t.Short()
+	}
+	return t.String()
+}
+
+// argumentSchema returns the schema of an argument of a module function.
+func (d Descriptions) argumentSchema(arg *modFunctionArg) *jsonschema.Schema {
+	prop := &jsonschema.Schema{
+		Description: d.argument(arg),
+	}
+	switch arg.TypeDef.Kind {
+	case dagger.TypeDefKindStringKind:
+		prop.Type = "string"
+	case dagger.TypeDefKindIntegerKind:
+		prop.Type = "integer"
+	case dagger.TypeDefKindBooleanKind:
+		prop.Type = "boolean"
+	case dagger.TypeDefKindVoidKind:
+		prop.Type = "null"
+	case dagger.TypeDefKindEnumKind:
+		prop.Type = "string"
+		for _, v := range arg.TypeDef.AsEnum.ValueNames() {
+			prop.Enum = append(prop.Enum, v)
+		}
+	// case dagger.TypeDefKindScalarKind:
+	// 	return t.AsScalar.Name
+	// case dagger.TypeDefKindInputKind:
+	// 	return t.AsInput.Name
+	// case dagger.TypeDefKindObjectKind:
+	// 	return t.AsObject.Name
+	// case dagger.TypeDefKindInterfaceKind:
+	// 	return t.AsInterface.Name
+	case dagger.TypeDefKindListKind:
+		prop.Type = "array"
+		prop.Items = &jsonschema.Schema{
+			Type: arg.TypeDef.AsList.ElementTypeDef.String(),
+		}
+	default:
+		panic(fmt.Sprintf("unsupported type: %s", arg.TypeDef.Kind))
+	}
+
+	if d.Arguments && arg.DefaultValue != "" {
+		var v any
+		if err := json.Unmarshal([]byte(arg.DefaultValue), &v); err == nil {
+			prop.Default = v
+		}
+	}
+	return prop
+}
+
+// truncate cuts s to n characters, at the end of a line or word if possible.
+func truncate(s string, n int) string {
+	const ellipsis = " [...]"
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	cut := n - len(ellipsis)
+	if cut <= 0 {
+		return strings.ToValidUTF8(s[:n], "")
+	}
+	if i := strings.LastIndexAny(s[:cut], "\n "); i > cut/2 {
+		cut = i
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(s[:cut], "")) + ellipsis
+}
+
+// Instructions describes the modules the tools come from, for the system
+// prompt, unless module tools already include the description of their module.
+func (t Tools) Instructions() string {
+	sb := new(strings.Builder)
+	seen := map[string]bool{}
+	for _, tool := range t {
+		mt, ok := tool.(*ModuleTool)
+		if !ok || mt.descriptions.Module || mt.mod.Description == "" || seen[mt.mod.Name] {
+			continue
+		}
+		seen[mt.mod.Name] = true
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(sb, "Tools prefixed with %q come from the %s module:\n\n%s", mt.mod.Name+"_", mt.mod.Name, strings.TrimSpace(mt.mod.Description))
+	}
+	return sb.String()
+}
@@ -0,0 +1,22 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 10); got != "short" {
+		t.Errorf("truncate() = %q", got)
+	}
+	for _, n := range []int{3, 15} {
+		got := truncate(strings.Repeat("é", 20), n)
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%d) = %q, invalid UTF-8", n, got)
+		}
+		if len(got) > n {
+			t.Errorf("truncate(%d) = %q, too long", n, got)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Command returns the `dagger call` command line equivalent to calling the
+// tool with args.
+//
+// Constructor arguments that aren't plain values (secrets loaded by
+// InitFromEnv) are passed from the environment variable they come from.
+func (t *ModuleTool) Command(args map[string]any) string {
+	ref := t.mod.ModRef
+	if ref == "" {
+		ref = t.mod.Name
+	}
+	parts := []string{"dagger", "-m", shellQuote(ref), "call"}
+
+	var ctorArgs []*modFunctionArg
+	if ctor := t.mod.MainObject.AsObject.Constructor; ctor != nil {
+		ctorArgs = ctor.Args
+	}
+	for _, arg := range ctorArgs {
+		v, ok := t.args[arg.Name]
+		if !ok {
+			continue
+		}
+		value, ok := flagValue(v)
+		if !ok {
+			value = "env:" + envName(t.mod, arg)
+		}
+		parts = append(parts, "--"+arg.FlagName(), shellQuote(value))
+	}
+
+	parts = append(parts, t.fn.CmdName())
+
+	seen := map[string]bool{}
+	for _, arg := range t.fn.Args {
+		v, ok := args[arg.Name]
+		if !ok {
+			continue
+		}
+		seen[arg.Name] = true
+		parts = append(parts, flag(arg.FlagName(), v)...)
+	}
+	// Arguments the function doesn't declare would be rejected by the CLI,
+	// but are rendered to show what was asked for.
+	extra := make([]string, 0, len(args))
+	for name := range args {
+		if !seen[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		parts = append(parts, flag(cliName(name), args[name])...)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func flag(name string, v any) []string {
+	if b, ok := v.(bool); ok {
+		if b {
+			return []string{"--" + name}
+		}
+		return []string{"--" + name + "=false"}
+	}
+	value, ok := flagValue(v)
+	if !ok {
+		data, _ := json.Marshal(v)
+		value = string(data)
+	}
+	return []string{"--" + name, shellQuote(value)}
+}
+
+// flagValue renders a value the way the dagger CLI parses it.
+func flagValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool, float64, int, int64:
+		return fmt.Sprint(v), true
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := flagValue(item)
+			if !ok {
+				return "", false
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), true
+	}
+	return "", false
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// DryRunResult is returned to the caller in place of the result of a call in
+// dry-run mode.
+type DryRunResult struct {
+	DryRun  bool   `json:"dryRun"`
+	Message string `json:"message"`
+	Query   string `json:"query,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// DryRun is a transport that doesn't execute calls. It logs the query and
+// command line of every call and answers with a DryRunResult.
+var DryRun Transport = TransportFunc(func(_ context.Context, inv *Invocation) (json.RawMessage, error) {
+	if inv.Command != "" {
+		fmt.Fprintf(os.Stderr, "dry run: %s\n", inv.Command)
+	} else {
+		fmt.Fprintf(os.Stderr, "dry run: %s(%s)\n", inv.Tool, inv.Arguments)
+	}
+	return json.Marshal(&DryRunResult{
+		DryRun:  true,
+		Message: fmt.Sprintf("Dry run: %s would have executed, but nothing was run. Assume it succeeded.", inv.Tool),
+		Query:   inv.Query,
+		Command: inv.Command,
+	})
+})
+
+// DryRun switches every tool to the DryRun transport.
+func (t Tools) DryRun() {
+	t.WrapTransport(func(Transport) Transport {
+		return DryRun
+	})
+}
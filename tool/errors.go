@@ -0,0 +1,37 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+var ErrNotFound = errors.New("tool not found")
+
+// ArgumentsError is returned for calls whose arguments don't match the
+// parameters of the tool.
+type ArgumentsError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *ArgumentsError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Tool, strings.Join(e.Problems, "; "))
+}
+
+// QueryError is returned when the engine fails to execute the query of a
+// call.
+type QueryError struct {
+	Tool   string
+	Errors gqlerror.List
+}
+
+func (e *QueryError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Message)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Tool, strings.Join(msgs, "; "))
+}
@@ -0,0 +1,163 @@
+package tool
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// maxOutput is the number of characters of stdout and stderr kept by
+// ExecError.Compact.
+const maxOutput = 1500
+
+// ExecError is returned when a command run by a module fails, e.g. `gh issue
+// comment` with a bad issue number.
+type ExecError struct {
+	Tool     string
+	Command  string
+	ExitCode int
+	Stdout   string
+	Stderr   string
+
+	// Err is the error as returned by the engine.
+	Err error
+}
+
+func (e *ExecError) Error() string {
+	msg := fmt.Sprintf("%s: %q exited with code %d", e.Tool, e.Command, e.ExitCode)
+	if line := lastLine(e.Stderr); line != "" {
+		msg += ": " + line
+	}
+	return msg
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Compact renders the error for the model: the command, its exit code and
+// the end of its output.
+func (e *ExecError) Compact() string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "command %q failed with exit code %d", e.Command, e.ExitCode)
+	if s := strings.TrimSpace(e.Stderr); s != "" {
+		fmt.Fprintf(sb, "\nstderr:\n%s", tail(s, maxOutput))
+	}
+	if s := strings.TrimSpace(e.Stdout); s != "" {
+		fmt.Fprintf(sb, "\nstdout:\n%s", tail(s, maxOutput))
+	}
+	return sb.String()
+}
+
+// execMessage matches the message of exec errors, as reported by the engine
+// and repeated in the output of the module runtime when nested calls fail.
+var execMessage = regexp.MustCompile(`process "((?:[^"\\]|\\.)*)" did not complete successfully: exit code: (\d+)`)
+
+// parseExecError extracts the failing command out of an engine error, or
+// returns nil if the error didn't come from a command.
+//
+// Module functions run in the module runtime: when a command they run fails,
+// the engine reports the runtime as the failing command and the actual
+// command is in its output. The innermost command is reported.
+func parseExecError(tool string, gqlErr *gqlerror.Error) *ExecError {
+	e, ok := execFromExtensions(gqlErr.Extensions)
+	if !ok {
+		e, ok = execFromText(gqlErr.Message)
+		if !ok {
+			return nil
+		}
+	}
+	for {
+		inner, ok := execFromText(e.Stderr)
+		if !ok {
+			inner, ok = execFromText(e.Stdout)
+		}
+		if !ok {
+			break
+		}
+		e = inner
+	}
+	e.Tool = tool
+	e.Err = gqlErr
+	return e
+}
+
+func execFromExtensions(ext map[string]any) (*ExecError, bool) {
+	if typ, _ := ext["_type"].(string); typ != "EXEC_ERROR" {
+		return nil, false
+	}
+	e := &ExecError{}
+	if code, ok := ext["exitCode"].(float64); ok {
+		e.ExitCode = int(code)
+	}
+	if args, ok := ext["cmd"].([]any); ok {
+		cmd := make([]string, 0, len(args))
+		for _, arg := range args {
+			s, _ := arg.(string)
+			cmd = append(cmd, shellQuote(s))
+		}
+		e.Command = strings.Join(cmd, " ")
+	}
+	e.Stdout, _ = ext["stdout"].(string)
+	e.Stderr, _ = ext["stderr"].(string)
+	return e, true
+}
+
+// execFromText parses the last exec error message in text, along with the
+// output that follows it:
+//
+//	process "gh issue comment 0" did not complete successfully: exit code: 1
+//
+//	Stdout:
+//	...
+//	Stderr:
+//	...
+func execFromText(text string) (*ExecError, bool) {
+	matches := execMessage.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return nil, false
+	}
+	m := matches[len(matches)-1]
+
+	e := &ExecError{Command: text[m[2]:m[3]]}
+	if cmd, err := strconv.Unquote(`"` + e.Command + `"`); err == nil {
+		e.Command = cmd
+	}
+	e.ExitCode, _ = strconv.Atoi(text[m[4]:m[5]])
+
+	rest := text[m[1]:]
+	if i := strings.Index(rest, "Stdout:\n"); i >= 0 {
+		e.Stdout = rest[i+len("Stdout:\n"):]
+		rest = rest[:i]
+	}
+	if i := strings.Index(e.Stdout, "Stderr:\n"); i >= 0 {
+		e.Stderr = e.Stdout[i+len("Stderr:\n"):]
+		e.Stdout = e.Stdout[:i]
+	} else if i := strings.Index(rest, "Stderr:\n"); i >= 0 {
+		e.Stderr = rest[i+len("Stderr:\n"):]
+	}
+	e.Stdout = strings.TrimSpace(e.Stdout)
+	e.Stderr = strings.TrimSpace(e.Stderr)
+	return e, true
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return strings.TrimSpace(s[strings.LastIndex(s, "\n")+1:])
+}
+
+// tail keeps the last n characters of s.
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := len(s) - n
+	// Don't split lines if it can be helped.
+	if i := strings.IndexByte(s[cut:], '\n'); i >= 0 && i < n/2 {
+		cut += i + 1
+	}
+	return fmt.Sprintf("[... %d characters omitted]\n%s", cut, strings.ToValidUTF8(s[cut:], ""))
+}
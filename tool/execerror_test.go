@@ -0,0 +1,169 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ghFailure is the message of the engine when `gh` fails in a container.
+const ghFailure = `input: container.from.withExec.stdout process "gh issue comment 0 --body hi" did not complete successfully: exit code: 1
+
+Stdout:
+
+Stderr:
+GraphQL: Could not resolve to an issue or pull request with the number of 0. (repository.issueOrPullRequest)
+`
+
+func TestParseExecError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     *gqlerror.Error
+		want    *ExecError
+		notExec bool
+	}{
+		{
+			name: "message",
+			err:  &gqlerror.Error{Message: ghFailure},
+			want: &ExecError{
+				Command:  "gh issue comment 0 --body hi",
+				ExitCode: 1,
+				Stderr:   "GraphQL: Could not resolve to an issue or pull request with the number of 0. (repository.issueOrPullRequest)",
+			},
+		},
+		{
+			name: "stdout and stderr",
+			err: &gqlerror.Error{Message: `input: container.from.withExec.stdout process "trufflehog git file:///src --json --fail" did not complete successfully: exit code: 183
+
+Stdout:
+{"SourceName":"trufflehog - git","DetectorName":"AWS"}
+{"SourceName":"trufflehog - git","DetectorName":"Github"}
+
+Stderr:
+2025-01-10T10:00:00Z	info-0	trufflehog	finished scanning	{"chunks": 52}
+`},
+			want: &ExecError{
+				Command:  "trufflehog git file:///src --json --fail",
+				ExitCode: 183,
+				Stdout:   "{\"SourceName\":\"trufflehog - git\",\"DetectorName\":\"AWS\"}\n{\"SourceName\":\"trufflehog - git\",\"DetectorName\":\"Github\"}",
+				Stderr:   "2025-01-10T10:00:00Z\tinfo-0\ttrufflehog\tfinished scanning\t{\"chunks\": 52}",
+			},
+		},
+		{
+			name: "quoted command",
+			err:  &gqlerror.Error{Message: `input: container.from.withExec.sync process "sh -c \"exit 3\"" did not complete successfully: exit code: 3`},
+			want: &ExecError{Command: `sh -c "exit 3"`, ExitCode: 3},
+		},
+		{
+			name: "nested in the module runtime",
+			err: &gqlerror.Error{Message: `input: github.issueComment process "/runtime" did not complete successfully: exit code: 2
+
+Stdout:
+
+Stderr:
+Error: ` + ghFailure},
+			want: &ExecError{
+				Command:  "gh issue comment 0 --body hi",
+				ExitCode: 1,
+				Stderr:   "GraphQL: Could not resolve to an issue or pull request with the number of 0. (repository.issueOrPullRequest)",
+			},
+		},
+		{
+			name: "extensions",
+			err: &gqlerror.Error{
+				Message: `process "/runtime" did not complete successfully: exit code: 2`,
+				Extensions: map[string]any{
+					"_type":    "EXEC_ERROR",
+					"cmd":      []any{"/runtime"},
+					"exitCode": float64(2),
+					"stdout":   "",
+					"stderr":   "Error: " + ghFailure,
+				},
+			},
+			want: &ExecError{
+				Command:  "gh issue comment 0 --body hi",
+				ExitCode: 1,
+				Stderr:   "GraphQL: Could not resolve to an issue or pull request with the number of 0. (repository.issueOrPullRequest)",
+			},
+		},
+		{
+			name: "extensions only",
+			err: &gqlerror.Error{
+				Message: "exec failed",
+				Extensions: map[string]any{
+					"_type":    "EXEC_ERROR",
+					"cmd":      []any{"git", "push", "origin", "my branch"},
+					"exitCode": float64(128),
+					"stderr":   "fatal: could not read Username for 'https://github.com'",
+				},
+			},
+			want: &ExecError{
+				Command:  "git push origin 'my branch'",
+				ExitCode: 128,
+				Stderr:   "fatal: could not read Username for 'https://github.com'",
+			},
+		},
+		{
+			name:    "not an exec error",
+			err:     &gqlerror.Error{Message: `failed to get content of "README.md": no such file or directory`},
+			notExec: true,
+		},
+		{
+			name:    "other extensions",
+			err:     &gqlerror.Error{Message: "rate limited", Extensions: map[string]any{"_type": "OTHER"}},
+			notExec: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseExecError("github_issue-comment", tt.err)
+			if tt.notExec {
+				if got != nil {
+					t.Fatalf("got %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("not parsed")
+			}
+			if got.Tool != "github_issue-comment" || got.Err != tt.err {
+				t.Errorf("tool = %q, err = %v", got.Tool, got.Err)
+			}
+			if got.Command != tt.want.Command || got.ExitCode != tt.want.ExitCode || got.Stdout != tt.want.Stdout || got.Stderr != tt.want.Stderr {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecErrorMessages(t *testing.T) {
+	e := &ExecError{
+		Tool:     "github_issue-comment",
+		Command:  "gh issue comment 0",
+		ExitCode: 1,
+		Stdout:   strings.Repeat("line\n", 1000),
+		Stderr:   "warning: retrying\nGraphQL: Could not resolve to an issue",
+	}
+	if got, want := e.Error(), `github_issue-comment: "gh issue comment 0" exited with code 1: GraphQL: Could not resolve to an issue`; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	compact := e.Compact()
+	if !strings.HasPrefix(compact, "command \"gh issue comment 0\" failed with exit code 1\nstderr:\nwarning: retrying") {
+		t.Errorf("Compact() = %q", compact)
+	}
+	if !strings.Contains(compact, "characters omitted]\nline\n") || len(compact) > 2*maxOutput {
+		t.Errorf("stdout was not cut: %d characters", len(compact))
+	}
+}
+
+func TestTail(t *testing.T) {
+	got := tail(strings.Repeat("é", 10), 5)
+	if !utf8.ValidString(got) {
+		t.Errorf("tail() = %q, invalid UTF-8", got)
+	}
+	if !strings.HasSuffix(got, "éé") {
+		t.Errorf("tail() = %q, want the last runes", got)
+	}
+}
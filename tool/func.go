@@ -0,0 +1,130 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+)
+
+// FuncTool is a tool implemented in Go, for helpers that don't need a
+// containerized module: clocks, math, configuration lookups.
+//
+// Calls go through a Transport like those of module tools, so they can be
+// recorded, replayed or retried the same way.
+type FuncTool struct {
+	gate
+
+	name        string
+	description string
+	schema      *jsonschema.Schema
+	transport   Transport
+}
+
+// NewFuncTool returns a tool whose calls are executed by transport, with the
+// arguments validated against schema.
+func NewFuncTool(name, description string, schema *jsonschema.Schema, transport Transport) *FuncTool {
+	return &FuncTool{
+		gate: gate{
+			safety:      deriveSafety(name, description),
+			annotations: deriveAnnotations(description),
+		},
+		name:        name,
+		description: description,
+		schema:      schema,
+		transport:   transport,
+	}
+}
+
+// Func returns a tool calling fn with the arguments decoded into an Args.
+//
+// The schema of the arguments is derived from Args (see jsonschema.For): use
+// `json` and `description` struct tags to document them. The result is sent
+// back JSON encoded.
+func Func[Args, Result any](name, description string, fn func(ctx context.Context, args Args) (Result, error)) *FuncTool {
+	return NewFuncTool(name, description, jsonschema.For[Args](), TransportFunc(func(ctx context.Context, inv *Invocation) (json.RawMessage, error) {
+		var args Args
+		if err := json.Unmarshal([]byte(inv.Arguments), &args); err != nil {
+			return nil, &ArgumentsError{Tool: inv.Tool, Problems: []string{err.Error()}}
+		}
+		result, err := fn(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(result)
+	}))
+}
+
+// Arg declares a string argument of a tool created with NewFunction.
+type Arg struct {
+	Name        string
+	Description string
+	Optional    bool
+}
+
+// NewFunction returns a tool taking string arguments, executed by transport.
+// Like module functions, the tool is named "<namespace>_<name>" with name in
+// kebab case.
+func NewFunction(namespace, name, description string, args []Arg, transport Transport) *FuncTool {
+	schema := &jsonschema.Schema{
+		Type:       "object",
+		Properties: map[string]*jsonschema.Schema{},
+		Required:   []string{},
+	}
+	for _, arg := range args {
+		schema.Properties[arg.Name] = &jsonschema.Schema{Type: "string", Description: arg.Description}
+		if !arg.Optional {
+			schema.Required = append(schema.Required, arg.Name)
+		}
+	}
+	return NewFuncTool(namespace+"_"+cliName(name), description, schema, transport)
+}
+
+func (t *FuncTool) Name() string {
+	return t.name
+}
+
+func (t *FuncTool) Description() string {
+	return t.description
+}
+
+func (t *FuncTool) Schema() *jsonschema.Schema {
+	return t.schema
+}
+
+// Transport returns the transport used to execute calls.
+func (t *FuncTool) Transport() Transport {
+	return t.transport
+}
+
+// SetTransport replaces the transport used to execute calls.
+func (t *FuncTool) SetTransport(transport Transport) {
+	t.transport = transport
+}
+
+func (t *FuncTool) Call(ctx context.Context, arguments string) (string, error) {
+	arguments, err := t.approve(ctx, &ApprovalRequest{
+		Tool:      t.name,
+		Function:  t.name,
+		Arguments: arguments,
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, err := decodeArguments(t.name, t.schema, arguments); err != nil {
+		return "", err
+	}
+
+	if t.transport == nil {
+		return "", fmt.Errorf("%s: no transport configured", t.name)
+	}
+	data, err := t.transport.Execute(ctx, &Invocation{
+		Tool:      t.name,
+		Arguments: arguments,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
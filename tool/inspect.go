@@ -0,0 +1,96 @@
+package tool
+
+import (
+	"os"
+	"strings"
+
+	"dagger.io/dagger"
+)
+
+// ModuleInfo describes a module tools were loaded from, to diagnose what a
+// module exposes and what it needs.
+type ModuleInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Ref         string `json:"ref,omitempty"`
+
+	// Constructor lists the arguments of the constructor of the module,
+	// which InitFromEnv reads from the environment.
+	Constructor []*ConstructorArg `json:"constructor,omitempty"`
+
+	// Tools are the names of the tools of the module.
+	Tools []string `json:"tools"`
+
+	// Skipped lists the functions that aren't tools, because they take or
+	// return types that can't be used from a model.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// ConstructorArg is an argument of the constructor of a module.
+type ConstructorArg struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+
+	// Env is the environment variable the argument is read from, and Set
+	// whether it is set.
+	Env string `json:"env"`
+	Set bool   `json:"set"`
+}
+
+// Modules describes the modules the tools come from, in order.
+func (t Tools) Modules() []*ModuleInfo {
+	modules := []*ModuleInfo{}
+	byName := map[string]*ModuleInfo{}
+	for _, tool := range t {
+		mt, ok := tool.(*ModuleTool)
+		if !ok {
+			continue
+		}
+		info, ok := byName[mt.mod.Name]
+		if !ok {
+			info = moduleInfo(mt.mod)
+			byName[mt.mod.Name] = info
+			modules = append(modules, info)
+		}
+		info.Tools = append(info.Tools, mt.Name())
+	}
+	return modules
+}
+
+func moduleInfo(mod *moduleDef) *ModuleInfo {
+	info := &ModuleInfo{
+		Name:        mod.Name,
+		Description: firstLine(mod.Description),
+		Ref:         mod.ModRef,
+	}
+	if ctor := mod.MainObject.AsObject.Constructor; ctor != nil {
+		for _, arg := range ctor.Args {
+			env := envName(mod, arg)
+			info.Constructor = append(info.Constructor, &ConstructorArg{
+				Name:        arg.Name,
+				Type:        arg.TypeDef.String(),
+				Description: firstLine(arg.Description),
+				Env:         env,
+				Set:         os.Getenv(env) != "",
+			})
+		}
+	}
+	_, info.Skipped = GetSupportedFunctions(mod.MainObject.AsFunctionProvider())
+	return info
+}
+
+// envName is the environment variable a constructor argument is read from:
+// GITHUB_TOKEN for the token of the github module.
+func envName(mod *moduleDef, arg *modFunctionArg) string {
+	return strings.ToUpper(mod.Name) + "_" + strings.ToUpper(arg.Name)
+}
+
+// Returns is the type returned by the function of the tool, or "" if it
+// returns nothing.
+func (t *ModuleTool) Returns() string {
+	if t.fn.ReturnType == nil || t.fn.ReturnType.Kind == dagger.TypeDefKindVoidKind {
+		return ""
+	}
+	return t.fn.ReturnType.String()
+}
@@ -0,0 +1,192 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MCPServer is a connection to an MCP server, whose tools can be used
+// alongside module tools.
+type MCPServer struct {
+	// Name prefixes the names of the tools of the server: "<name>_<tool>",
+	// with the characters models don't accept replaced by underscores.
+	Name string
+
+	client *client.Client
+}
+
+// ConnectMCP connects to the MCP server at target: either the URL of a
+// streamable HTTP server or a command line starting a stdio server.
+//
+// HTTP requests carry the bearer token found in $<NAME>_TOKEN, if any, like
+// modules are configured from the environment.
+func ConnectMCP(ctx context.Context, name, target string) (*MCPServer, error) {
+	var (
+		c   *client.Client
+		err error
+	)
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		var opts []transport.StreamableHTTPCOption
+		if token := os.Getenv(strings.ToUpper(name) + "_TOKEN"); token != "" {
+			opts = append(opts, transport.WithHTTPHeaders(map[string]string{"Authorization": "Bearer " + token}))
+		}
+		c, err = client.NewStreamableHttpClient(target, opts...)
+		if err == nil {
+			err = c.Start(ctx)
+		}
+	} else {
+		args := strings.Fields(target)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("mcp %s: empty command", name)
+		}
+		c, err = client.NewStdioMCPClient(args[0], os.Environ(), args[1:]...)
+		if err == nil {
+			if stderr, ok := client.GetStderr(c); ok {
+				go io.Copy(os.Stderr, stderr)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp %s: %w", name, err)
+	}
+
+	s := &MCPServer{Name: name, client: c}
+	init := mcp.InitializeRequest{}
+	init.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	init.Params.ClientInfo = mcp.Implementation{Name: "langdag", Version: "0.1.0"}
+	if _, err := c.Initialize(ctx, init); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mcp %s: unable to initialize: %w", name, err)
+	}
+	return s, nil
+}
+
+// Close disconnects from the server, stopping stdio servers.
+func (s *MCPServer) Close() error {
+	return s.client.Close()
+}
+
+// Tools lists the tools of the server.
+//
+// Input schemas are given to models as is, and arguments validated against
+// the subset of JSON schema this package understands (see jsonschema.Schema).
+// The arguments of tools whose schema can't be decoded at all aren't
+// validated. Read-only and destructive hints set the safety of the tools,
+// other hints their annotations.
+func (s *MCPServer) Tools(ctx context.Context) (Tools, error) {
+	defs := []mcp.Tool{}
+	request := mcp.ListToolsRequest{}
+	for {
+		res, err := s.client.ListTools(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("mcp %s: unable to list tools: %w", s.Name, err)
+		}
+		defs = append(defs, res.Tools...)
+		if res.NextCursor == "" {
+			break
+		}
+		request.Params.Cursor = res.NextCursor
+	}
+
+	tools := make(Tools, 0, len(defs))
+	seen := map[string]string{}
+	for _, def := range defs {
+		name := mcpToolName(s.Name, def.Name)
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("mcp %s: tools %q and %q are both named %s", s.Name, other, def.Name, name)
+		}
+		seen[name] = def.Name
+
+		data, err := json.Marshal(def.InputSchema)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := jsonschema.Parse(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mcp %s: tool %s: %v, its arguments won't be validated\n", s.Name, def.Name, err)
+			schema = &jsonschema.Schema{Type: "object", Raw: data}
+		}
+
+		t := NewFuncTool(name, def.Description, schema, s.transport(def.Name))
+		switch hints := def.Annotations; {
+		case hints.ReadOnlyHint != nil && *hints.ReadOnlyHint:
+			t.SetSafety(ReadOnly)
+		case hints.DestructiveHint != nil && *hints.DestructiveHint:
+			t.SetSafety(Destructive)
+		case hints.DestructiveHint != nil:
+			t.SetSafety(Mutating)
+		}
+		t.SetAnnotations(Annotations{
+			Title:      def.Annotations.Title,
+			Idempotent: def.Annotations.IdempotentHint,
+			OpenWorld:  def.Annotations.OpenWorldHint,
+		})
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+var invalidToolName = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// mcpToolName returns the name of the tool name of server, restricted to the
+// characters and length accepted by model APIs.
+func mcpToolName(server, name string) string {
+	name = invalidToolName.ReplaceAllString(server+"_"+name, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// transport calls the tool named name on the server.
+func (s *MCPServer) transport(name string) Transport {
+	return TransportFunc(func(ctx context.Context, inv *Invocation) (json.RawMessage, error) {
+		var args map[string]any
+		if err := json.Unmarshal([]byte(inv.Arguments), &args); err != nil {
+			return nil, &ArgumentsError{Tool: inv.Tool, Problems: []string{err.Error()}}
+		}
+
+		request := mcp.CallToolRequest{}
+		request.Params.Name = name
+		request.Params.Arguments = args
+		res, err := s.client.CallTool(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		text := resultText(res)
+		if res.IsError {
+			return nil, fmt.Errorf("%s: %s", inv.Tool, text)
+		}
+		return json.Marshal(text)
+	})
+}
+
+// resultText renders the content of a tool result for the model: text as
+// is, anything else JSON encoded.
+func resultText(res *mcp.CallToolResult) string {
+	if len(res.Content) == 0 && res.StructuredContent != nil {
+		data, _ := json.Marshal(res.StructuredContent)
+		return string(data)
+	}
+	parts := make([]string, 0, len(res.Content))
+	for _, content := range res.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+			continue
+		}
+		data, _ := json.Marshal(content)
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, "\n")
+}
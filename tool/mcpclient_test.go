@@ -0,0 +1,100 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// notesServer builds the example MCP server, standing in for third-party
+// servers, and returns the command starting it.
+func notesServer(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "mcpserver")
+	cmd := exec.Command("go", "build", "-o", bin, "../examples/mcpserver")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("unable to build the MCP server: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestMCPServer(t *testing.T) {
+	ctx := context.Background()
+	srv, err := ConnectMCP(ctx, "notes", notesServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	tools, err := srv.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]Safety{
+		"notes_add":    Mutating,
+		"notes_list":   ReadOnly,
+		"notes_delete": Destructive,
+		"notes_search": ReadOnly,
+	} {
+		tl := tools.Get(name)
+		if tl == nil {
+			t.Fatalf("no tool %s in %v", name, names(tools))
+		}
+		if got := SafetyOf(tl); got != want {
+			t.Errorf("%s is %s, want %s", name, got, want)
+		}
+	}
+
+	// The schema is given to models whole.
+	search := tools.Get("notes_search")
+	schema := search.Schema().Map()
+	props, _ := schema["properties"].(map[string]any)
+	query, _ := props["query"].(map[string]any)
+	if _, ok := schema["$defs"]; !ok || query["pattern"] == nil {
+		t.Errorf("schema was not passed through: %v", schema)
+	}
+
+	if _, err := tools.Get("notes_add").Call(ctx, `{"text": "buy milk"}`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		arguments string
+		want      string
+		invalid   bool
+	}{
+		{`{"query": "milk", "limit": 1, "order": "desc"}`, `"1: buy milk"`, false},
+		{`{"query": null, "labels": {"topic": "food"}}`, `"1: buy milk"`, false},
+		{`{"query": "eggs"}`, `""`, false},
+		{`{"query": 1}`, "", true},
+		// References aren't followed: the server validates what's left.
+		{`{"limit": 0}`, `"1: buy milk"`, false},
+	}
+	for _, tt := range tests {
+		got, err := search.Call(ctx, tt.arguments)
+		var argsErr *ArgumentsError
+		switch {
+		case tt.invalid && !errors.As(err, &argsErr):
+			t.Errorf("search(%s) = %v, want an arguments error", tt.arguments, err)
+		case !tt.invalid && err != nil:
+			t.Errorf("search(%s): %v", tt.arguments, err)
+		case got != tt.want:
+			t.Errorf("search(%s) = %s, want %s", tt.arguments, got, tt.want)
+		}
+	}
+}
+
+func TestMCPToolName(t *testing.T) {
+	for _, tt := range []struct{ server, name, want string }{
+		{"notes", "add", "notes_add"},
+		{"fs", "read.file", "fs_read_file"},
+		{"web", "fetch url/v2", "web_fetch_url_v2"},
+		{"x", strings.Repeat("a", 70), "x_" + strings.Repeat("a", 62)},
+	} {
+		if got := mcpToolName(tt.server, tt.name); got != tt.want {
+			t.Errorf("mcpToolName(%q, %q) = %q, want %q", tt.server, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"dagger.io/dagger"
+	"dagger.io/dagger/querybuilder"
+	"github.com/aluzzardi/langdag/jsonschema"
+)
+
+// LoadOption configures how tools are loaded.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	safety       map[string]Safety
+	annotations  map[string]Annotations
+	descriptions Descriptions
+}
+
+// WithSafety overrides the safety derived from the module metadata. The
+// function is named either after the tool ("github_issue-close") or after
+// the module function ("issue-close").
+func WithSafety(function string, safety Safety) LoadOption {
+	return func(o *loadOptions) {
+		o.safety[function] = safety
+	}
+}
+
+func Load(ctx context.Context, dag *dagger.Client, ref string, args map[string]any, opts ...LoadOption) (Tools, error) {
+	options := &loadOptions{
+		safety:       map[string]Safety{},
+		annotations:  map[string]Annotations{},
+		descriptions: DefaultDescriptions,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	mod, err := initializeModule(ctx, dag, ref, false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %w", ref, err)
+	}
+
+	var o functionProvider = mod.MainObject.AsFunctionProvider()
+
+	fns, _ := GetSupportedFunctions(o)
+
+	tools := make(Tools, 0, len(fns))
+
+	for _, fn := range fns {
+		tool := NewModuleTool(dag, mod, fn, args)
+		tool.descriptions = options.descriptions
+		if s, ok := options.safety[tool.Name()]; ok {
+			tool.SetSafety(s)
+		} else if s, ok := options.safety[fn.CmdName()]; ok {
+			tool.SetSafety(s)
+		}
+		annotations := tool.Annotations().merge(options.annotations[fn.CmdName()])
+		tool.SetAnnotations(annotations.merge(options.annotations[tool.Name()]))
+		tools = append(tools, tool)
+	}
+
+	return tools, nil
+}
+
+func LoadAll(ctx context.Context, dag *dagger.Client, refs []string, opts ...LoadOption) (Tools, error) {
+	tools := Tools{}
+	for _, ref := range refs {
+		t, err := Load(ctx, dag, ref, nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, t...)
+	}
+
+	return tools, nil
+}
+
+// ModuleTool is a tool backed by the function of a dagger module.
+type ModuleTool struct {
+	gate
+
+	dag          *dagger.Client
+	mod          *moduleDef
+	fn           *modFunction
+	args         map[string]any
+	transport    Transport
+	descriptions Descriptions
+}
+
+func NewModuleTool(dag *dagger.Client, mod *moduleDef, fn *modFunction, args map[string]any) *ModuleTool {
+	if args == nil {
+		args = make(map[string]any)
+	}
+	t := &ModuleTool{
+		gate: gate{
+			safety:      deriveSafety(fn.CmdName(), fn.Description),
+			annotations: deriveAnnotations(fn.Description),
+		},
+		dag:          dag,
+		mod:          mod,
+		fn:           fn,
+		args:         args,
+		descriptions: DefaultDescriptions,
+	}
+	if dag != nil {
+		t.transport = &graphqlTransport{client: dag.GraphQLClient()}
+	}
+	return t
+}
+
+// Transport returns the transport used to execute calls.
+func (t *ModuleTool) Transport() Transport {
+	return t.transport
+}
+
+// SetTransport replaces the transport used to execute calls.
+func (t *ModuleTool) SetTransport(transport Transport) {
+	t.transport = transport
+}
+
+func (t *ModuleTool) Name() string {
+	return t.mod.Name + "_" + t.fn.CmdName()
+}
+
+func (t *ModuleTool) Description() string {
+	return t.descriptions.function(t.mod, t.fn)
+}
+
+// Schema returns the JSON schema of the arguments of the tool.
+func (t *ModuleTool) Schema() *jsonschema.Schema {
+	schema := &jsonschema.Schema{
+		Type:       "object",
+		Properties: map[string]*jsonschema.Schema{},
+		Required:   []string{},
+	}
+	for _, arg := range t.fn.Args {
+		schema.Properties[arg.Name] = t.descriptions.argumentSchema(arg)
+		if arg.IsRequired() {
+			schema.Required = append(schema.Required, arg.Name)
+		}
+	}
+	return schema
+}
+
+func (t *ModuleTool) InitFromEnv() error {
+	ctor := t.mod.MainObject.AsObject.Constructor
+
+	for _, arg := range ctor.Args {
+		k := envName(t.mod, arg)
+		fmt.Fprintf(os.Stderr, "Loading option %s::%s from %s\n", t.mod.Name, arg.Name, k)
+		v := os.Getenv(k)
+		if v == "" {
+			return fmt.Errorf("%q not set", k)
+		}
+
+		switch arg.TypeDef.Kind {
+		case dagger.TypeDefKindStringKind:
+			t.args[arg.Name] = v
+		case dagger.TypeDefKindObjectKind:
+			if arg.TypeDef.AsObject.Name != "Secret" {
+				return fmt.Errorf("unsupported type: %s", arg.TypeDef.AsObject.Name)
+			}
+
+			t.args[arg.Name] = t.dag.SetSecret(k, v)
+		// case dagger.TypeDefKindIntegerKind:
+		// case dagger.TypeDefKindBooleanKind:
+		// case dagger.TypeDefKindVoidKind:
+		// case dagger.TypeDefKindListKind:
+		default:
+			panic(fmt.Sprintf("unsupported type: %s", arg.TypeDef.Kind))
+		}
+	}
+
+	return nil
+}
+
+func (t *ModuleTool) Call(ctx context.Context, arguments string) (string, error) {
+	arguments, err := t.approve(ctx, &ApprovalRequest{
+		Tool:      t.Name(),
+		Function:  t.mod.Name + " " + t.fn.CmdName(),
+		Arguments: arguments,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	args, err := decodeArguments(t.Name(), t.Schema(), arguments)
+	if err != nil {
+		return "", err
+	}
+
+	q := querybuilder.Query()
+
+	// Select module
+	q = q.Select(t.mod.Name)
+	// Bind top-level args
+	for k, v := range t.args {
+		q = q.Arg(k, v)
+	}
+
+	// Select function
+	q = q.Select(t.fn.Name)
+	for arg, v := range args {
+		q = q.Arg(arg, t.queryValue(arg, v))
+	}
+	// Objects are returned by ID: the query needs a scalar selection.
+	if t.returnsObject() {
+		q = q.Select("id")
+	}
+
+	gql, err := q.Build(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to build query: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "sending query: %s\n", gql)
+	reportProgress(ctx, "calling %s %s", t.mod.Name, t.fn.CmdName())
+
+	if t.transport == nil {
+		return "", fmt.Errorf("%s: no transport configured", t.Name())
+	}
+
+	data, err := t.transport.Execute(ctx, &Invocation{
+		Tool:      t.Name(),
+		Arguments: arguments,
+		Query:     gql,
+		Command:   t.Command(args),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (t *ModuleTool) returnsObject() bool {
+	rt := t.fn.ReturnType
+	if rt != nil && rt.Kind == dagger.TypeDefKindListKind {
+		rt = rt.AsList.ElementTypeDef
+	}
+	return rt != nil && (rt.Kind == dagger.TypeDefKindObjectKind || rt.Kind == dagger.TypeDefKindInterfaceKind)
+}
+
+// enumValue is an enum argument: enums are sent as literals rather than
+// strings.
+type enumValue string
+
+func (enumValue) IsEnum() {}
+
+func (t *ModuleTool) queryValue(name string, v any) any {
+	for _, arg := range t.fn.Args {
+		if arg.Name != name || arg.TypeDef.Kind != dagger.TypeDefKindEnumKind {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			return enumValue(s)
+		}
+	}
+	return v
+}
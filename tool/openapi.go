@@ -0,0 +1,146 @@
+package tool
+
+import (
+	"strings"
+
+	"dagger.io/dagger"
+	"github.com/aluzzardi/langdag/jsonschema"
+)
+
+// OpenAPI returns an OpenAPI 3.1 document describing the tools as HTTP
+// endpoints, as served by the gateway package: each tool is called with
+// `POST /tools/{name}` and its arguments as a JSON body, and responds with
+// {"result": ...}.
+//
+// Module tools are documented from the metadata of their function: its whole
+// description, arguments and return type. They are tagged with their module.
+func (t Tools) OpenAPI(title, version, serverURL string) map[string]any {
+	paths := map[string]any{
+		"/tools": map[string]any{
+			"get": map[string]any{
+				"operationId": "listTools",
+				"summary":     "List the tools",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The tools, with the JSON schemas of their arguments.",
+						"content": map[string]any{
+							"application/json": map[string]any{
+								"schema": map[string]any{"type": "array", "items": map[string]any{"type": "object"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tool := range t {
+		paths["/tools/"+tool.Name()] = map[string]any{"post": operation(tool)}
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Error": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"error":    map[string]any{"type": "string"},
+						"problems": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+						"details":  map[string]any{"type": "string"},
+					},
+					"required": []string{"error"},
+				},
+			},
+		},
+	}
+	if serverURL != "" {
+		doc["servers"] = []map[string]any{{"url": serverURL}}
+	}
+	return doc
+}
+
+func operation(t Tool) map[string]any {
+	description := t.Description()
+	op := map[string]any{
+		"operationId": t.Name(),
+		"x-safety":    SafetyOf(t),
+	}
+	if mt, ok := t.(*ModuleTool); ok {
+		description = strings.TrimSpace(mt.fn.Description)
+		op["tags"] = []string{mt.mod.Name}
+	}
+	summary := AnnotationsOf(t).Title
+	if summary == "" {
+		summary = firstLine(description)
+	}
+	op["summary"] = summary
+	if description != summary {
+		op["description"] = description
+	}
+
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content": map[string]any{
+				"application/json": map[string]any{
+					"schema": map[string]any{"$ref": "#/components/schemas/Error"},
+				},
+			},
+		}
+	}
+	op["requestBody"] = map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": t.Schema().Map()},
+		},
+	}
+	op["responses"] = map[string]any{
+		"200": map[string]any{
+			"description": "The result of the call.",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": responseSchema(t).Map()},
+			},
+		},
+		"400": errorResponse("The arguments can't be read or don't match the schema."),
+		"403": errorResponse("The call was denied."),
+		"404": errorResponse("There is no such tool."),
+		"413": errorResponse("The arguments are too large."),
+		"500": errorResponse("The call failed for another reason."),
+		"502": errorResponse("The function failed."),
+	}
+	return op
+}
+
+// responseSchema returns the schema of the responses of a tool: its output
+// schema, if any, or an object holding any result.
+func responseSchema(t Tool) *jsonschema.Schema {
+	if o, ok := t.(interface{ OutputSchema() *jsonschema.Schema }); ok {
+		if schema := o.OutputSchema(); schema != nil {
+			return schema
+		}
+	}
+	result := &jsonschema.Schema{}
+	if mt, ok := t.(*ModuleTool); ok && mt.fn.ReturnType != nil {
+		rt := mt.fn.ReturnType
+		switch {
+		case rt.Kind == dagger.TypeDefKindVoidKind:
+			result.Type = "null"
+		case mt.returnsObject() && rt.Kind != dagger.TypeDefKindListKind:
+			// Objects are returned by ID.
+			result.Type = "object"
+			result.Properties = map[string]*jsonschema.Schema{
+				"id": {Type: "string", Description: "ID of the " + rt.String()},
+			}
+		}
+		result.Description = returnType(rt)
+	}
+	return &jsonschema.Schema{
+		Type:       "object",
+		Properties: map[string]*jsonschema.Schema{"result": result},
+	}
+}
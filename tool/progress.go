@@ -0,0 +1,239 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Progress receives progress messages of a tool call, such as the phases of
+// module calls.
+type Progress func(message string)
+
+type progressKey struct{}
+
+// WithProgress returns a context reporting the progress of tool calls to fn.
+func WithProgress(ctx context.Context, fn Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func reportProgress(ctx context.Context, format string, args ...any) {
+	if fn, ok := ctx.Value(progressKey{}).(Progress); ok {
+		fn(fmt.Sprintf(format, args...))
+	}
+}
+
+var (
+	// MCPHeartbeat is the interval of the progress notifications sent while
+	// MCP tool calls run.
+	MCPHeartbeat = 10 * time.Second
+
+	// MCPLogInterval is the minimum interval between two dagger log lines
+	// sent as progress notifications.
+	MCPLogInterval = time.Second
+)
+
+// callField is the metadata field MCPHooks tags tool calls with, for
+// MCPHandler to know their request ID.
+const callField = "langdag/call"
+
+// mcpCall is an in-flight MCP tool call.
+type mcpCall struct {
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	progress Progress
+	lastLog  time.Time
+}
+
+func (c *mcpCall) report(message string) {
+	if c.progress != nil {
+		c.progress(message)
+	}
+}
+
+func (c *mcpCall) log(line string) {
+	c.mu.Lock()
+	if time.Since(c.lastLog) < MCPLogInterval {
+		c.mu.Unlock()
+		return
+	}
+	c.lastLog = time.Now()
+	c.mu.Unlock()
+	c.report(line)
+}
+
+var (
+	inflightMu sync.Mutex
+	inflight   = map[string]*mcpCall{}
+)
+
+// callKey identifies a request of a client session.
+func callKey(ctx context.Context, id any) string {
+	rid, ok := id.(mcp.RequestId)
+	if !ok {
+		rid = mcp.NewRequestId(id)
+	}
+	session := ""
+	if s := server.ClientSessionFromContext(ctx); s != nil {
+		session = s.SessionID()
+	}
+	return session + "/" + rid.String()
+}
+
+// MCPHooks returns the hooks MCP servers need for clients to cancel tool
+// calls, along with MCPCancelHandler:
+//
+//	s := server.NewMCPServer(name, version, server.WithHooks(tool.MCPHooks()))
+//	s.AddNotificationHandler("notifications/cancelled", tool.MCPCancelHandler())
+func MCPHooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	// Handlers don't get the ID of requests: pass it along in the metadata.
+	hooks.AddBeforeCallTool(func(ctx context.Context, id any, request *mcp.CallToolRequest) {
+		if request.Params.Meta == nil {
+			request.Params.Meta = &mcp.Meta{}
+		}
+		if request.Params.Meta.AdditionalFields == nil {
+			request.Params.Meta.AdditionalFields = map[string]any{}
+		}
+		request.Params.Meta.AdditionalFields[callField] = callKey(ctx, id)
+	})
+	return hooks
+}
+
+// MCPCancelHandler returns an MCP server handler of cancellation
+// notifications, cancelling the context of the tool call.
+func MCPCancelHandler() server.NotificationHandlerFunc {
+	return func(ctx context.Context, notification mcp.JSONRPCNotification) {
+		id, ok := notification.Params.AdditionalFields["requestId"]
+		if !ok {
+			return
+		}
+		inflightMu.Lock()
+		call := inflight[callKey(ctx, id)]
+		inflightMu.Unlock()
+		if call == nil {
+			return
+		}
+		reason, _ := notification.Params.AdditionalFields["reason"].(string)
+		if reason == "" {
+			reason = "no reason given"
+		}
+		call.cancel(fmt.Errorf("cancelled by the client: %s", reason))
+	}
+}
+
+// startMCPCall tracks a tool call, returning its context: it is cancelled
+// when the client cancels the request, and reports progress to the client if
+// it asked for it. done must be called once the call returns.
+func startMCPCall(ctx context.Context, name string, request mcp.CallToolRequest) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	call := &mcpCall{cancel: cancel}
+
+	key := ""
+	var token mcp.ProgressToken
+	if meta := request.Params.Meta; meta != nil {
+		key, _ = meta.AdditionalFields[callField].(string)
+		token = meta.ProgressToken
+	}
+	if key == "" {
+		// Without MCPHooks, calls can't be cancelled but still get logs.
+		key = fmt.Sprintf("%p", call)
+	}
+
+	stop := make(chan struct{})
+	if srv := server.ServerFromContext(ctx); srv != nil && token != nil {
+		var (
+			mu       sync.Mutex
+			progress float64
+		)
+		call.progress = func(message string) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress++
+			// Best effort: notifications are dropped when the client lags.
+			_ = srv.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+				"progressToken": token,
+				"progress":      progress,
+				"message":       message,
+			})
+		}
+		ctx = WithProgress(ctx, call.progress)
+
+		go func() {
+			start := time.Now()
+			ticker := time.NewTicker(MCPHeartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					call.report(fmt.Sprintf("%s running for %s", name, time.Since(start).Round(time.Second)))
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	inflightMu.Lock()
+	inflight[key] = call
+	inflightMu.Unlock()
+
+	return ctx, func() {
+		close(stop)
+		inflightMu.Lock()
+		if inflight[key] == call {
+			delete(inflight, key)
+		}
+		inflightMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// MCPLogOutput returns a writer for dagger.WithLogOutput, forwarding the lines
+// of the dagger log to the in-flight MCP tool call as progress, and to w if
+// not nil.
+//
+// The dagger session is shared by all calls and its log doesn't tell which
+// call a line belongs to: lines are only forwarded while a single call is in
+// flight, so that clients never see the activity of others. Concurrent calls
+// only get heartbeats.
+func MCPLogOutput(w io.Writer) io.Writer {
+	r, pw := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				if call := soleCall(); call != nil {
+					call.log(line)
+				}
+			}
+			if w != nil {
+				fmt.Fprintln(w, scanner.Text())
+			}
+		}
+		// Keep draining so the dagger session never blocks on its log.
+		io.Copy(io.Discard, r)
+	}()
+	return pw
+}
+
+// soleCall returns the in-flight call, if there is exactly one.
+func soleCall() *mcpCall {
+	inflightMu.Lock()
+	defer inflightMu.Unlock()
+	if len(inflight) != 1 {
+		return nil
+	}
+	for _, call := range inflight {
+		return call
+	}
+	return nil
+}
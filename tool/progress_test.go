@@ -0,0 +1,64 @@
+package tool
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMCPLogOutput(t *testing.T) {
+	interval := MCPLogInterval
+	MCPLogInterval = 0
+	t.Cleanup(func() { MCPLogInterval = interval })
+
+	received := map[string]chan string{}
+	for _, key := range []string{"a", "b"} {
+		ch := make(chan string, 10)
+		received[key] = ch
+		inflightMu.Lock()
+		inflight[key] = &mcpCall{progress: func(message string) { ch <- message }}
+		inflightMu.Unlock()
+	}
+	t.Cleanup(func() {
+		inflightMu.Lock()
+		delete(inflight, "a")
+		delete(inflight, "b")
+		inflightMu.Unlock()
+	})
+
+	// Lines are written to the log once handled.
+	log := make(chan string, 10)
+	w := MCPLogOutput(writerFunc(func(p []byte) (int, error) {
+		log <- string(p)
+		return len(p), nil
+	}))
+	defer w.(io.Closer).Close()
+	fmt.Fprintln(w, "both calls are running")
+	<-log
+
+	inflightMu.Lock()
+	delete(inflight, "b")
+	inflightMu.Unlock()
+	fmt.Fprintln(w, "only a is running")
+
+	<-log
+
+	select {
+	case got := <-received["a"]:
+		if got != "only a is running" {
+			t.Errorf("a got %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a got no line")
+	}
+	if len(received["b"]) != 0 {
+		t.Errorf("b got %q", <-received["b"])
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,252 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Resource is a document about the modules tools come from, for clients to
+// look up on demand rather than having it all in tool descriptions.
+type Resource struct {
+	URI         string
+	Name        string
+	Description string
+	MIMEType    string
+	Text        string
+}
+
+// Resources documents the modules of the tools. URIs are of the form
+// dagger://<module>/<kind>/<name>:
+//
+//	dagger://github                         the module
+//	dagger://github/dependencies            its dependencies
+//	dagger://github/objects/Github          object types
+//	dagger://github/enums/State             enum types
+//	dagger://github/inputs/Filter           input types
+//	dagger://github/functions/issue-list    the functions of the tools
+func (t Tools) Resources() []*Resource {
+	resources := []*Resource{}
+	seen := map[string]bool{}
+	for _, tool := range t {
+		mt, ok := tool.(*ModuleTool)
+		if !ok {
+			continue
+		}
+		mod := mt.mod
+		if !seen[mod.Name] {
+			seen[mod.Name] = true
+			resources = append(resources, moduleResources(mod)...)
+		}
+		resources = append(resources, &Resource{
+			URI:         resourceURI(mod, "functions", mt.fn.CmdName()),
+			Name:        mod.Name + " " + mt.fn.CmdName(),
+			Description: fmt.Sprintf("Reference of the %s function, called by the %s tool", mt.fn.CmdName(), mt.Name()),
+			MIMEType:    "text/markdown",
+			Text:        mt.Reference(),
+		})
+	}
+	return resources
+}
+
+func resourceURI(mod *moduleDef, parts ...string) string {
+	return "dagger://" + strings.Join(append([]string{mod.Name}, parts...), "/")
+}
+
+func moduleResources(mod *moduleDef) []*Resource {
+	resources := []*Resource{
+		{
+			URI:         resourceURI(mod),
+			Name:        mod.Name,
+			Description: fmt.Sprintf("Overview of the %s module", mod.Name),
+			MIMEType:    "text/markdown",
+			Text:        moduleOverview(mod),
+		},
+		{
+			URI:         resourceURI(mod, "dependencies"),
+			Name:        mod.Name + " dependencies",
+			Description: fmt.Sprintf("Modules the %s module depends on", mod.Name),
+			MIMEType:    "text/markdown",
+			Text:        moduleDependencies(mod),
+		},
+	}
+
+	add := func(kind string, typeDefs []*modTypeDef, render func(*modTypeDef) string) {
+		for _, typeDef := range typeDefs {
+			name := typeDef.String()
+			resources = append(resources, &Resource{
+				URI:         resourceURI(mod, kind, name),
+				Name:        mod.Name + " " + name,
+				Description: firstLine(typeDef.Description()),
+				MIMEType:    "text/markdown",
+				Text:        render(typeDef),
+			})
+		}
+	}
+	add("objects", mod.Objects, objectReference)
+	add("enums", mod.Enums, enumReference)
+	add("inputs", mod.Inputs, inputReference)
+	return resources
+}
+
+func moduleOverview(mod *moduleDef) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "# %s\n\n", mod.Name)
+	if mod.Description != "" {
+		fmt.Fprintf(sb, "%s\n\n", strings.TrimSpace(mod.Description))
+	}
+	if mod.ModRef != "" {
+		fmt.Fprintf(sb, "Source: `%s`\n\n", mod.ModRef)
+	}
+	fns, _ := GetSupportedFunctions(mod.MainObject.AsFunctionProvider())
+	if len(fns) > 0 {
+		sb.WriteString("## Functions\n\n")
+		for _, fn := range fns {
+			fmt.Fprintf(sb, "- [%s](%s): %s\n", fn.CmdName(), resourceURI(mod, "functions", fn.CmdName()), fn.Short())
+		}
+	}
+	return sb.String()
+}
+
+func moduleDependencies(mod *moduleDef) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "# Dependencies of %s\n\n", mod.Name)
+	if len(mod.Dependencies) == 0 {
+		sb.WriteString("None.\n")
+		return sb.String()
+	}
+	for _, dep := range mod.Dependencies {
+		fmt.Fprintf(sb, "- **%s**: %s\n", dep.Name, dep.Short())
+		if dep.ModRef != "" {
+			fmt.Fprintf(sb, "  - ref: `%s`\n", dep.ModRef)
+		}
+		if dep.RefPin != "" {
+			fmt.Fprintf(sb, "  - pin: `%s`\n", dep.RefPin)
+		}
+	}
+	return sb.String()
+}
+
+func objectReference(typeDef *modTypeDef) string {
+	obj := typeDef.AsObject
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "# %s\n\n", obj.Name)
+	if obj.Description != "" {
+		fmt.Fprintf(sb, "%s\n\n", strings.TrimSpace(obj.Description))
+	}
+	if len(obj.Fields) > 0 {
+		sb.WriteString("## Fields\n\n")
+		for _, f := range obj.Fields {
+			fmt.Fprintf(sb, "- `%s` (%s)%s\n", f.Name, f.TypeDef, describe(f.Description))
+		}
+		sb.WriteString("\n")
+	}
+	if len(obj.Functions) > 0 {
+		sb.WriteString("## Functions\n\n")
+		for _, fn := range obj.Functions {
+			fmt.Fprintf(sb, "- `%s`%s\n", fn.CmdName(), describe(fn.Short()))
+		}
+	}
+	return sb.String()
+}
+
+func enumReference(typeDef *modTypeDef) string {
+	enum := typeDef.AsEnum
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "# %s\n\n", enum.Name)
+	if enum.Description != "" {
+		fmt.Fprintf(sb, "%s\n\n", strings.TrimSpace(enum.Description))
+	}
+	sb.WriteString("## Values\n\n")
+	for _, v := range enum.Values {
+		fmt.Fprintf(sb, "- `%s`%s\n", v.Name, describe(v.Description))
+	}
+	return sb.String()
+}
+
+func inputReference(typeDef *modTypeDef) string {
+	input := typeDef.AsInput
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "# %s\n\n", input.Name)
+	if input.Description != "" {
+		fmt.Fprintf(sb, "%s\n\n", strings.TrimSpace(input.Description))
+	}
+	sb.WriteString("## Fields\n\n")
+	for _, f := range input.Fields {
+		fmt.Fprintf(sb, "- `%s` (%s)%s\n", f.Name, f.TypeDef, describe(f.Description))
+	}
+	return sb.String()
+}
+
+// Reference documents the function of the tool in markdown: its whole
+// description, arguments, return type and how to call it from the CLI.
+func (t *ModuleTool) Reference() string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "# %s %s\n\n", t.mod.Name, t.fn.CmdName())
+	if t.fn.Description != "" {
+		fmt.Fprintf(sb, "%s\n\n", strings.TrimSpace(t.fn.Description))
+	}
+
+	if len(t.fn.Args) > 0 {
+		sb.WriteString("## Arguments\n\n")
+		for _, arg := range t.fn.Args {
+			required := ""
+			if arg.IsRequired() {
+				required = ", required"
+			}
+			fmt.Fprintf(sb, "- `%s` (%s%s)%s\n", arg.Name, arg.TypeDef, required, describe(arg.Long()))
+		}
+		sb.WriteString("\n")
+	}
+
+	if t.fn.ReturnType != nil {
+		if returns := returnType(t.fn.ReturnType); returns != "" {
+			fmt.Fprintf(sb, "## Returns\n\n%s\n\n", returns)
+		}
+	}
+
+	example := map[string]any{}
+	for _, arg := range t.fn.RequiredArgs() {
+		example[arg.Name] = "<" + arg.TypeDef.String() + ">"
+	}
+	fmt.Fprintf(sb, "## Usage\n\n```sh\n%s\n```\n", t.Command(example))
+	return sb.String()
+}
+
+// describe renders a description following a name, indenting continuation
+// lines so they stay in the list item.
+func describe(description string) string {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return ""
+	}
+	return ": " + strings.ReplaceAll(description, "\n", "\n  ")
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
+
+// ToMCPResource returns the definition of a resource for MCP servers.
+func ToMCPResource(r *Resource) mcp.Resource {
+	return mcp.NewResource(r.URI, r.Name,
+		mcp.WithResourceDescription(r.Description),
+		mcp.WithMIMEType(r.MIMEType),
+	)
+}
+
+// MCPResourceHandler returns an MCP server handler reading r.
+func MCPResourceHandler(r *Resource) server.ResourceHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      r.URI,
+				MIMEType: r.MIMEType,
+				Text:     r.Text,
+			},
+		}, nil
+	}
+}
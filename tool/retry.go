@@ -0,0 +1,51 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aluzzardi/langdag/retry"
+)
+
+// Retry retries failed calls according to policy, or to the policy of the
+// tool in overrides, keyed by tool name.
+//
+// Calling a tool again after a failure may repeat its side effects, e.g. post
+// a comment twice, so only read-only and idempotent tools are retried by
+// default (see SafeToRetry). Calls to other tools are bounded by the timeout of
+// policy but only retried with an override.
+func (t Tools) Retry(policy retry.Policy, overrides map[string]retry.Policy) {
+	for _, tool := range t {
+		tt, ok := tool.(transporter)
+		if !ok {
+			continue
+		}
+		p, ok := overrides[tool.Name()]
+		if !ok {
+			p = policy
+			if !SafeToRetry(tool) {
+				p.MaxRetries = 0
+			}
+		}
+		next := tt.Transport()
+		tt.SetTransport(TransportFunc(func(ctx context.Context, inv *Invocation) (json.RawMessage, error) {
+			var result json.RawMessage
+			err := p.Do(ctx, inv.Tool, func(ctx context.Context) error {
+				var err error
+				result, err = next.Execute(ctx, inv)
+				return err
+			})
+			return result, err
+		}))
+	}
+}
+
+// SafeToRetry reports whether a tool can be called again after a failure:
+// read-only and idempotent tools can.
+func SafeToRetry(t Tool) bool {
+	if SafetyOf(t) == ReadOnly {
+		return true
+	}
+	idempotent := AnnotationsOf(t).Idempotent
+	return idempotent != nil && *idempotent
+}
@@ -0,0 +1,61 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aluzzardi/langdag/retry"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		override    bool
+		want        int
+	}{
+		{"issue-view", "View an issue.", false, 3},
+		{"issue-comment", "Add a comment to an issue.", false, 1},
+		{"issue-label", "Label an issue.\nidempotent: true", false, 3},
+		{"issue-comment", "Add a comment to an issue.", true, 3},
+		{"issue-close", "Close an issue.", false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			tools := Tools{Func(tt.name, tt.description, func(context.Context, struct{}) (string, error) {
+				calls++
+				return "", errors.New("503 service unavailable")
+			})}
+			policy := retry.Policy{MaxRetries: 2, InitialDelay: time.Millisecond}
+			overrides := map[string]retry.Policy{}
+			if tt.override {
+				overrides[tt.name] = policy
+			}
+			tools.Retry(policy, overrides)
+
+			if _, err := tools[0].Call(context.Background(), `{}`); err == nil {
+				t.Fatal("call succeeded")
+			}
+			if calls != tt.want {
+				t.Errorf("called %d times, want %d", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	tools := Tools{Func("issue-comment", "Add a comment to an issue.", func(ctx context.Context, _ struct{}) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})}
+	tools.Retry(retry.Policy{Timeout: 10 * time.Millisecond, MaxRetries: 2}, nil)
+
+	_, err := tools[0].Call(context.Background(), `{}`)
+	var timeout *retry.TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
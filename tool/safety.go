@@ -0,0 +1,269 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Safety describes the side effects of calling a tool.
+type Safety string
+
+const (
+	// ReadOnly tools only observe, e.g. listing issues.
+	ReadOnly Safety = "read-only"
+	// Mutating tools change state in a way that is easily undone, e.g.
+	// commenting on an issue.
+	Mutating Safety = "mutating"
+	// Destructive tools change state in a way that is hard to undo, e.g.
+	// closing a pull request.
+	Destructive Safety = "destructive"
+)
+
+func ParseSafety(s string) (Safety, error) {
+	switch Safety(s) {
+	case ReadOnly, Mutating, Destructive:
+		return Safety(s), nil
+	}
+	return "", fmt.Errorf("unknown safety %q (expected %s, %s or %s)", s, ReadOnly, Mutating, Destructive)
+}
+
+// AtLeast reports whether s is as dangerous as other or more.
+func (s Safety) AtLeast(other Safety) bool {
+	return s.level() >= other.level()
+}
+
+func (s Safety) level() int {
+	switch s {
+	case ReadOnly:
+		return 0
+	case Destructive:
+		return 2
+	}
+	return 1
+}
+
+var (
+	readOnlyVerbs = []string{
+		"list", "get", "view", "show", "search", "find", "read", "describe",
+		"inspect", "status", "diff", "log", "logs", "scan", "lint", "check",
+		"query", "fetch", "count", "version", "help",
+	}
+	mutatingVerbs = []string{
+		"create", "update", "set", "add", "write", "push", "post", "commit",
+		"send", "merge", "edit", "put", "patch", "upload", "apply", "deploy",
+		"publish", "assign", "label", "comment", "rename", "move", "copy",
+		"install", "run", "exec", "start", "stop", "restart", "trigger",
+		"approve", "enable", "disable", "open", "reopen", "tag", "release",
+	}
+	destructiveVerbs = []string{
+		"close", "delete", "remove", "rm", "destroy", "drop", "purge",
+		"reset", "revoke", "terminate", "kill", "wipe", "archive", "force",
+	}
+
+	// safetyPragma declares the safety of a function in its description, on
+	// a line of its own: "safety: read-only".
+	safetyPragma = regexp.MustCompile(`(?im)^\s*safety:\s*(read-only|mutating|destructive)\s*$`)
+)
+
+// deriveSafety guesses the safety of a function from its name and
+// description.
+//
+// A safety pragma in the description wins. Otherwise the words of the
+// function name are matched against well known verbs, the most dangerous
+// first: "create-check" is mutating even though "check" is a read-only verb.
+// Names without a known verb, like "git", are classified by the first word
+// of the description ("Find credentials in git repositories"). Functions that
+// can't be classified are considered mutating.
+func deriveSafety(name, description string) Safety {
+	if m := safetyPragma.FindStringSubmatch(description); m != nil {
+		return Safety(strings.ToLower(m[1]))
+	}
+
+	words := strings.FieldsFunc(cliName(name), func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	if s, ok := classify(words); ok {
+		return s
+	}
+	if first := strings.Fields(strings.ToLower(description)); len(first) > 0 {
+		// Descriptions may use the third person: "Lists", "Pushes".
+		w := first[0]
+		if s, ok := classify([]string{w, strings.TrimSuffix(w, "s"), strings.TrimSuffix(w, "es")}); ok {
+			return s
+		}
+	}
+	return Mutating
+}
+
+// classify returns the safety of the most dangerous verb among words.
+func classify(words []string) (Safety, bool) {
+	for _, level := range []struct {
+		safety Safety
+		verbs  []string
+	}{
+		{Destructive, destructiveVerbs},
+		{Mutating, mutatingVerbs},
+		{ReadOnly, readOnlyVerbs},
+	} {
+		for _, w := range words {
+			if slices.Contains(level.verbs, w) {
+				return level.safety, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ApprovalRequest describes a call awaiting approval.
+type ApprovalRequest struct {
+	Tool   string `json:"tool"`
+	Safety Safety `json:"safety"`
+
+	// Function is the function being called, e.g. "github issue-close" for
+	// module tools.
+	Function string `json:"function"`
+
+	// Arguments are the JSON encoded arguments of the call.
+	Arguments string `json:"arguments"`
+}
+
+func (r *ApprovalRequest) String() string {
+	args := r.Arguments
+	var v map[string]any
+	if json.Unmarshal([]byte(args), &v) == nil {
+		if data, err := json.MarshalIndent(v, "", "  "); err == nil {
+			args = string(data)
+		}
+	}
+	return fmt.Sprintf("%s (%s, %s)\n%s", r.Tool, r.Function, r.Safety, args)
+}
+
+// Approval is the outcome of an ApprovalRequest.
+type Approval struct {
+	Approved bool `json:"approved"`
+
+	// Arguments, if set, replace the arguments of the call.
+	Arguments string `json:"arguments,omitempty"`
+
+	// Reason explains a denial to the caller.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Approver decides whether a call may run.
+type Approver func(ctx context.Context, req *ApprovalRequest) (*Approval, error)
+
+// AllowAll approves every call.
+func AllowAll(context.Context, *ApprovalRequest) (*Approval, error) {
+	return &Approval{Approved: true}, nil
+}
+
+// DenyAll denies every call.
+func DenyAll(_ context.Context, req *ApprovalRequest) (*Approval, error) {
+	return &Approval{Reason: fmt.Sprintf("%s calls are not allowed", req.Safety)}, nil
+}
+
+// ParsePolicy returns the approver for a headless policy: "allow" or
+// "deny".
+func ParsePolicy(s string) (Approver, error) {
+	switch s {
+	case "allow":
+		return AllowAll, nil
+	case "deny":
+		return DenyAll, nil
+	}
+	return nil, fmt.Errorf("unknown approval policy %q (expected allow or deny)", s)
+}
+
+// DeniedError is returned for calls that were not approved.
+type DeniedError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("call to %s was denied", e.Tool)
+	}
+	return fmt.Sprintf("call to %s was denied: %s", e.Tool, e.Reason)
+}
+
+// gate holds the safety and annotations of a tool and the approver of its
+// calls.
+type gate struct {
+	safety      Safety
+	annotations Annotations
+	approver    Approver
+	approveFrom Safety
+}
+
+// Safety returns the safety of the tool.
+func (g *gate) Safety() Safety {
+	return g.safety
+}
+
+func (g *gate) SetSafety(s Safety) {
+	g.safety = s
+}
+
+// Annotations returns the annotations of the tool.
+func (g *gate) Annotations() Annotations {
+	return g.annotations
+}
+
+func (g *gate) SetAnnotations(a Annotations) {
+	g.annotations = a
+}
+
+func (g *gate) requireApproval(min Safety, approve Approver) {
+	g.approveFrom = min
+	g.approver = approve
+}
+
+// approve asks the approver, if any, whether a call may run. It returns the
+// arguments to run the call with.
+func (g *gate) approve(ctx context.Context, req *ApprovalRequest) (string, error) {
+	if g.approver == nil || !g.safety.AtLeast(g.approveFrom) {
+		return req.Arguments, nil
+	}
+
+	req.Safety = g.safety
+	approval, err := g.approver(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("approval of %s failed: %w", req.Tool, err)
+	}
+	if !approval.Approved {
+		return "", &DeniedError{Tool: req.Tool, Reason: approval.Reason}
+	}
+	if approval.Arguments != "" {
+		if !json.Valid([]byte(approval.Arguments)) {
+			return "", fmt.Errorf("approval of %s: edited arguments are not valid JSON", req.Tool)
+		}
+		return approval.Arguments, nil
+	}
+	return req.Arguments, nil
+}
+
+// SafetyOf returns the safety of a tool. Tools that don't declare one are
+// considered mutating.
+func SafetyOf(t Tool) Safety {
+	if s, ok := t.(interface{ Safety() Safety }); ok {
+		return s.Safety()
+	}
+	return Mutating
+}
+
+// RequireApproval makes calls to tools at least as dangerous as min go
+// through approve first.
+//
+// Only the tools of this package can be gated.
+func (t Tools) RequireApproval(min Safety, approve Approver) {
+	for _, tool := range t {
+		if g, ok := tool.(interface{ requireApproval(Safety, Approver) }); ok {
+			g.requireApproval(min, approve)
+		}
+	}
+}
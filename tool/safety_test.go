@@ -0,0 +1,35 @@
+package tool
+
+import "testing"
+
+func TestDeriveSafety(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        Safety
+	}{
+		{"issue-list", "List issues in a GitHub repository.", ReadOnly},
+		{"issue-comment", "Add a comment to a GitHub issue.", Mutating},
+		{"issue-close", "Close issue", Destructive},
+		{"pull-request-close", "Close a pull request", Destructive},
+		{"set-status", "", Mutating},
+		{"commit-status", "", Mutating},
+		{"create-check", "", Mutating},
+		{"update-log", "", Mutating},
+		{"push-diff", "", Mutating},
+		{"force-push", "", Destructive},
+		{"git", "Find credentials in git repositories. Returns the JSON output of the scan", ReadOnly},
+		{"git", "Pushes the repository.", Mutating},
+		{"git", "", Mutating},
+		{"frobnicate", "Frobnicate the widget.", Mutating},
+		{"issue-list", "List issues.\nsafety: destructive", Destructive},
+		{"IssueList", "", ReadOnly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deriveSafety(tt.name, tt.description); got != tt.want {
+				t.Errorf("deriveSafety(%q, %q) = %s, want %s", tt.name, tt.description, got, tt.want)
+			}
+		})
+	}
+}
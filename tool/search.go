@@ -0,0 +1,161 @@
+package tool
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: term frequency saturation and document length
+// normalization.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Index ranks tools against free text with BM25, a lexical scoring that
+// doesn't need any model. Tools are indexed by name, description and the
+// names and descriptions of their arguments; names count double.
+type Index struct {
+	tools     Tools
+	terms     []map[string]int
+	lengths   []int
+	avgLength float64
+	docFreq   map[string]int
+}
+
+func NewIndex(tools Tools) *Index {
+	ix := &Index{
+		tools:   tools,
+		terms:   make([]map[string]int, len(tools)),
+		lengths: make([]int, len(tools)),
+		docFreq: map[string]int{},
+	}
+
+	total := 0
+	for i, t := range tools {
+		tokens := tokenize(t.Name())
+		tokens = append(tokens, tokens...)
+		tokens = append(tokens, tokenize(t.Description())...)
+		if schema := t.Schema(); schema != nil {
+			for name, prop := range schema.Properties {
+				tokens = append(tokens, tokenize(name)...)
+				tokens = append(tokens, tokenize(prop.Description)...)
+			}
+		}
+
+		freqs := map[string]int{}
+		for _, tok := range tokens {
+			freqs[tok]++
+		}
+		for tok := range freqs {
+			ix.docFreq[tok]++
+		}
+		ix.terms[i] = freqs
+		ix.lengths[i] = len(tokens)
+		total += len(tokens)
+	}
+	if len(tools) > 0 {
+		ix.avgLength = float64(total) / float64(len(tools))
+	}
+	return ix
+}
+
+// Search returns up to k tools matching query, best first. Tools sharing no
+// term with the query are left out.
+func (ix *Index) Search(query string, k int) Tools {
+	terms := map[string]bool{}
+	for _, tok := range tokenize(query) {
+		terms[tok] = true
+	}
+
+	type match struct {
+		tool  Tool
+		score float64
+	}
+	matches := []match{}
+	n := float64(len(ix.tools))
+	for i, t := range ix.tools {
+		score := 0.0
+		for term := range terms {
+			tf := float64(ix.terms[i][term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(ix.docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(ix.lengths[i])/ix.avgLength
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+		if score > 0 {
+			matches = append(matches, match{t, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+
+	tools := make(Tools, 0, len(matches))
+	for _, m := range matches {
+		tools = append(tools, m.tool)
+	}
+	return tools
+}
+
+// stopWords are too common to tell tools apart.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "do": true, "for": true, "from": true,
+	"i": true, "if": true, "in": true, "is": true, "it": true, "me": true,
+	"my": true, "of": true, "on": true, "or": true, "please": true, "the": true,
+	"this": true, "to": true, "what": true, "with": true, "you": true,
+}
+
+// tokenize splits text into lowercase words, breaking identifiers such as
+// "issue-list", "issue_list" and "issueList" into their parts.
+func tokenize(text string) []string {
+	tokens := []string{}
+	word := []rune{}
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		tok := stem(strings.ToLower(string(word)))
+		word = word[:0]
+		if !stopWords[tok] {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if unicode.IsUpper(r) && unicode.IsLower(prev) {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return tokens
+}
+
+// stem folds plurals so that "issues" matches "issue".
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	}
+	return word
+}
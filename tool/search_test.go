@@ -0,0 +1,65 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aluzzardi/langdag/jsonschema"
+)
+
+func names(tools Tools) []string {
+	out := []string{}
+	for _, t := range tools {
+		out = append(out, t.Name())
+	}
+	return out
+}
+
+func TestIndexSearch(t *testing.T) {
+	tools := Tools{
+		NewFuncTool("github_issue-list", "List the issues of a repository.", &jsonschema.Schema{}, nil),
+		NewFuncTool("github_issue-close", "Close an issue.", &jsonschema.Schema{}, nil),
+		NewFuncTool("github_pr-comment", "Comment on a pull request.", &jsonschema.Schema{
+			Properties: map[string]*jsonschema.Schema{"body": {Description: "Text of the comment"}},
+		}, nil),
+		NewFuncTool("trufflehog_scan", "Scan a git repository for leaked secrets.", &jsonschema.Schema{}, nil),
+	}
+	ix := NewIndex(tools)
+
+	tests := []struct {
+		query string
+		k     int
+		want  []string
+	}{
+		{query: "close the issue", k: 5, want: []string{"github_issue-close", "github_issue-list"}},
+		{query: "list issues", k: 5, want: []string{"github_issue-list", "github_issue-close"}},
+		{query: "list issues", k: 1, want: []string{"github_issue-list"}},
+		{query: "find leaked secrets", k: 5, want: []string{"trufflehog_scan"}},
+		{query: "leave a comment on the PR", k: 5, want: []string{"github_pr-comment"}},
+		{query: "deploy", k: 5, want: []string{}},
+		{query: "", k: 5, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := names(ix.Search(tt.query, tt.k)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := map[string][]string{
+		"issue-list":         {"issue", "list"},
+		"issue_list":         {"issue", "list"},
+		"issueList":          {"issue", "list"},
+		"List the issues":    {"list", "issue"},
+		"repositories":       {"repository"},
+		"Close a PR, please": {"close", "pr"},
+	}
+	for text, want := range tests {
+		if got := tokenize(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("tokenize(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dagger.io/dagger"
+	"github.com/aluzzardi/langdag/jsonschema"
+)
+
+// toolSnapshot is the serialized form of a Tool: either a module tool or a
+// Go function tool.
+//
+// Object types are only kept by name: type definitions loaded from the engine
+// reference each other and can't be serialized as is.
+type toolSnapshot struct {
+	Module            string        `json:"module,omitempty"`
+	ModuleDescription string        `json:"moduleDescription,omitempty"`
+	ModRef            string        `json:"modRef,omitempty"`
+	MainObject        string        `json:"mainObject,omitempty"`
+	Constructor       *modFunction  `json:"constructor,omitempty"`
+	Function          *modFunction  `json:"function,omitempty"`
+	Safety            Safety        `json:"safety,omitempty"`
+	Annotations       *Annotations  `json:"annotations,omitempty"`
+	Descriptions      *Descriptions `json:"descriptions,omitempty"`
+
+	Func *funcSnapshot `json:"func,omitempty"`
+}
+
+type funcSnapshot struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Schema      *jsonschema.Schema `json:"schema"`
+}
+
+// Snapshot serializes the definitions of the tools.
+//
+// Tools can be rebuilt from a snapshot with Restore, without a dagger engine.
+// Only the tools of this package can be serialized.
+func (t Tools) Snapshot() (json.RawMessage, error) {
+	snapshots := make([]*toolSnapshot, 0, len(t))
+	for _, tool := range t {
+		switch tool := tool.(type) {
+		case *ModuleTool:
+			snapshots = append(snapshots, &toolSnapshot{
+				Module:            tool.mod.Name,
+				ModuleDescription: tool.mod.Description,
+				ModRef:            tool.mod.ModRef,
+				MainObject:        tool.mod.MainObject.AsObject.Name,
+				Constructor:       shallowFunction(tool.mod.MainObject.AsObject.Constructor),
+				Function:          shallowFunction(tool.fn),
+				Safety:            tool.safety,
+				Annotations:       &tool.annotations,
+				Descriptions:      &tool.descriptions,
+			})
+		case *FuncTool:
+			snapshots = append(snapshots, &toolSnapshot{
+				Safety:      tool.safety,
+				Annotations: &tool.annotations,
+				Func: &funcSnapshot{
+					Name:        tool.name,
+					Description: tool.description,
+					Schema:      tool.schema,
+				},
+			})
+		default:
+			return nil, fmt.Errorf("unable to snapshot %s: unsupported tool type %T", tool.Name(), tool)
+		}
+	}
+	return json.Marshal(snapshots)
+}
+
+// Restore rebuilds tools from a Snapshot.
+//
+// Restored tools are not connected to an engine: calls go through transport.
+func Restore(data json.RawMessage, transport Transport) (Tools, error) {
+	var snapshots []*toolSnapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("unable to restore tools: %w", err)
+	}
+
+	mods := map[string]*moduleDef{}
+	tools := make(Tools, 0, len(snapshots))
+	for _, s := range snapshots {
+		if s.Func != nil {
+			tool := NewFuncTool(s.Func.Name, s.Func.Description, s.Func.Schema, transport)
+			if s.Safety != "" {
+				tool.SetSafety(s.Safety)
+			}
+			if s.Annotations != nil {
+				tool.SetAnnotations(*s.Annotations)
+			}
+			tools = append(tools, tool)
+			continue
+		}
+
+		mod, ok := mods[s.Module]
+		if !ok {
+			mainObject := &modTypeDef{
+				Kind: dagger.TypeDefKindObjectKind,
+				AsObject: &modObject{
+					Name:        s.MainObject,
+					Constructor: s.Constructor,
+				},
+			}
+			if mainObject.AsObject.Constructor == nil {
+				mainObject.AsObject.Constructor = &modFunction{ReturnType: mainObject}
+			}
+			mod = &moduleDef{
+				Name:        s.Module,
+				Description: s.ModuleDescription,
+				ModRef:      s.ModRef,
+				MainObject:  mainObject,
+				Objects:     []*modTypeDef{mainObject},
+			}
+			mods[s.Module] = mod
+		}
+		mod.MainObject.AsObject.Functions = append(mod.MainObject.AsObject.Functions, s.Function)
+
+		tool := NewModuleTool(nil, mod, s.Function, nil)
+		tool.SetTransport(transport)
+		if s.Safety != "" {
+			tool.SetSafety(s.Safety)
+		}
+		if s.Annotations != nil {
+			tool.SetAnnotations(*s.Annotations)
+		}
+		if s.Descriptions != nil {
+			tool.descriptions = *s.Descriptions
+		}
+		tools = append(tools, tool)
+	}
+
+	return tools, nil
+}
+
+func shallowFunction(fn *modFunction) *modFunction {
+	if fn == nil {
+		return nil
+	}
+	cp := *fn
+	cp.ReturnType = shallowTypeDef(fn.ReturnType)
+	cp.Args = make([]*modFunctionArg, 0, len(fn.Args))
+	for _, arg := range fn.Args {
+		a := *arg
+		a.TypeDef = shallowTypeDef(arg.TypeDef)
+		cp.Args = append(cp.Args, &a)
+	}
+	return &cp
+}
+
+func shallowTypeDef(t *modTypeDef) *modTypeDef {
+	if t == nil {
+		return nil
+	}
+	cp := *t
+	if t.AsObject != nil {
+		cp.AsObject = &modObject{
+			Name:             t.AsObject.Name,
+			Description:      t.AsObject.Description,
+			SourceModuleName: t.AsObject.SourceModuleName,
+		}
+	}
+	if t.AsInterface != nil {
+		cp.AsInterface = &modInterface{
+			Name:             t.AsInterface.Name,
+			Description:      t.AsInterface.Description,
+			SourceModuleName: t.AsInterface.SourceModuleName,
+		}
+	}
+	if t.AsInput != nil {
+		input := &modInput{
+			Name:        t.AsInput.Name,
+			Description: t.AsInput.Description,
+		}
+		for _, f := range t.AsInput.Fields {
+			input.Fields = append(input.Fields, &modField{
+				Name:        f.Name,
+				Description: f.Description,
+				TypeDef:     shallowTypeDef(f.TypeDef),
+			})
+		}
+		cp.AsInput = input
+	}
+	if t.AsList != nil {
+		cp.AsList = &modList{ElementTypeDef: shallowTypeDef(t.AsList.ElementTypeDef)}
+	}
+	return &cp
+}
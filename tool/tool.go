@@ -3,316 +3,161 @@ package tool
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"strings"
+	"math"
 
-	"dagger.io/dagger"
-	"dagger.io/dagger/querybuilder"
-	"github.com/Khan/genqlient/graphql"
+	"github.com/aluzzardi/langdag/jsonschema"
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go"
 )
 
-func Load(ctx context.Context, dag *dagger.Client, ref string, args map[string]any) (Tools, error) {
-	mod, err := initializeModule(ctx, dag, ref, false)
-	if err != nil {
-		return nil, fmt.Errorf("unable to load %s: %w", ref, err)
-	}
-
-	var o functionProvider = mod.MainObject.AsFunctionProvider()
-
-	fns, _ := GetSupportedFunctions(o)
-
-	tools := make(Tools, 0, len(fns))
-
-	for _, fn := range fns {
-		tools = append(tools, NewTool(dag, mod, fn, args))
-	}
-
-	return tools, nil
-}
-
-func LoadAll(ctx context.Context, dag *dagger.Client, refs []string) (Tools, error) {
-	tools := Tools{}
-	for _, ref := range refs {
-		t, err := Load(ctx, dag, ref, nil)
-		if err != nil {
-			return nil, err
-		}
-		tools = append(tools, t...)
-	}
-
-	return tools, nil
-}
-
-type Tool struct {
-	dag  *dagger.Client
-	mod  *moduleDef
-	fn   *modFunction
-	args map[string]any
-}
-
-func NewTool(dag *dagger.Client, mod *moduleDef, fn *modFunction, args map[string]any) *Tool {
-	if args == nil {
-		args = make(map[string]any)
-	}
-	return &Tool{
-		dag:  dag,
-		mod:  mod,
-		fn:   fn,
-		args: args,
-	}
-}
+// Tool is a function a model can call.
+//
+// Tools are either backed by a dagger module function (ModuleTool) or by Go
+// code (FuncTool).
+type Tool interface {
+	Name() string
+	Description() string
 
-func (t *Tool) Name() string {
-	return t.mod.Name + "_" + t.fn.CmdName()
-}
+	// Schema is the JSON schema of the arguments. It describes an object.
+	Schema() *jsonschema.Schema
 
-func (t *Tool) Description() string {
-	return t.mod.Description + "\n" + t.fn.Short()
+	// Call runs the tool with JSON encoded arguments.
+	Call(ctx context.Context, arguments string) (string, error)
 }
 
-func (t *Tool) Params() openai.ChatCompletionToolParam {
-	properties := map[string]any{}
-	required := []string{}
-	for _, arg := range t.fn.Args {
-		props := map[string]any{
-			"type":        arg.TypeDef.String(),
-			"description": arg.Description,
-		}
-		switch arg.TypeDef.Kind {
-		case dagger.TypeDefKindStringKind:
-			props["type"] = "string"
-		case dagger.TypeDefKindIntegerKind:
-			props["type"] = "integer"
-		case dagger.TypeDefKindBooleanKind:
-			props["type"] = "boolean"
-		case dagger.TypeDefKindVoidKind:
-			props["type"] = "null"
-		// case dagger.TypeDefKindScalarKind:
-		// 	return t.AsScalar.Name
-		// case dagger.TypeDefKindEnumKind:
-		// 	return t.AsEnum.Name
-		// case dagger.TypeDefKindInputKind:
-		// 	return t.AsInput.Name
-		// case dagger.TypeDefKindObjectKind:
-		// 	return t.AsObject.Name
-		// case dagger.TypeDefKindInterfaceKind:
-		// 	return t.AsInterface.Name
-		case dagger.TypeDefKindListKind:
-			props["type"] = "array"
-			props["items"] = map[string]string{
-				"type": arg.TypeDef.AsList.ElementTypeDef.String(),
-			}
-		default:
-			panic(fmt.Sprintf("unsupported type: %s", arg.TypeDef.Kind))
-		}
-
-		properties[arg.Name] = props
-
-		if !arg.TypeDef.Optional {
-			required = append(required, arg.Name)
-		}
-	}
+// Params returns the definition of a tool for the OpenAI API.
+func Params(t Tool) openai.ChatCompletionToolParam {
 	return openai.ChatCompletionToolParam{
 		Type: openai.F(openai.ChatCompletionToolTypeFunction),
 		Function: openai.F(openai.FunctionDefinitionParam{
 			Name:        openai.String(t.Name()),
 			Description: openai.String(t.Description()),
 			// Strict:      openai.Bool(true),
-			Parameters: openai.F(openai.FunctionParameters{
-				"type":       "object",
-				"properties": properties,
-				"required":   required,
-				// "additionalProperties": false,
-			}),
+			Parameters: openai.F(openai.FunctionParameters(t.Schema().Map())),
 		}),
 	}
 }
 
-func (t *Tool) InitFromEnv() error {
-	ctor := t.mod.MainObject.AsObject.Constructor
-
-	for _, arg := range ctor.Args {
-		k := strings.ToUpper(t.mod.Name) + "_" + strings.ToUpper(arg.Name)
-		fmt.Fprintf(os.Stderr, "Loading option %s::%s from %s\n", t.mod.Name, arg.Name, k)
-		v := os.Getenv(k)
-		if v == "" {
-			return fmt.Errorf("%q not set", k)
-		}
-
-		switch arg.TypeDef.Kind {
-		case dagger.TypeDefKindStringKind:
-			t.args[arg.Name] = v
-		case dagger.TypeDefKindObjectKind:
-			if arg.TypeDef.AsObject.Name != "Secret" {
-				return fmt.Errorf("unsupported type: %s", arg.TypeDef.AsObject.Name)
+// ToMCP returns the definition of a tool for MCP servers.
+//
+// Annotations tell clients about the safety of the tool. Tools with an
+// output schema, such as module functions returning strings, declare it.
+func ToMCP(t Tool) mcp.Tool {
+	schema := t.Schema()
+	def := mcp.Tool{
+		Name:        t.Name(),
+		Description: t.Description(),
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: mcpProperties(schema),
+			Required:   schema.Required,
+		},
+		Annotations: mcpAnnotations(t),
+	}
+	if o, ok := t.(interface{ OutputSchema() *jsonschema.Schema }); ok {
+		if output := o.OutputSchema(); output != nil {
+			def.OutputSchema = mcp.ToolOutputSchema{
+				Type:       "object",
+				Properties: mcpProperties(output),
+				Required:   output.Required,
 			}
-
-			t.args[arg.Name] = t.dag.SetSecret(k, v)
-		// case dagger.TypeDefKindIntegerKind:
-		// case dagger.TypeDefKindBooleanKind:
-		// case dagger.TypeDefKindVoidKind:
-		// case dagger.TypeDefKindListKind:
-		default:
-			panic(fmt.Sprintf("unsupported type: %s", arg.TypeDef.Kind))
 		}
 	}
-
-	return nil
+	return def
 }
 
-func (t *Tool) ToMCP() mcp.Tool {
-	opts := []mcp.ToolOption{
-		mcp.WithDescription(t.Description()),
-	}
-	for _, arg := range t.fn.Args {
-		props := []mcp.PropertyOption{
-			mcp.Description(arg.Description),
-		}
-
-		if !arg.TypeDef.Optional {
-			props = append(props, mcp.Required())
-		}
-
-		switch arg.TypeDef.Kind {
-		case dagger.TypeDefKindStringKind:
-			opts = append(opts, mcp.WithString(arg.Name, props...))
-		case dagger.TypeDefKindIntegerKind:
-			opts = append(opts, mcp.WithNumber(arg.Name, props...))
-		case dagger.TypeDefKindBooleanKind:
-			opts = append(opts, mcp.WithBoolean(arg.Name, props...))
-		// case dagger.TypeDefKindVoidKind:
-		// 	props["type"] = "null"
-		// case dagger.TypeDefKindScalarKind:
-		// 	return t.AsScalar.Name
-		// case dagger.TypeDefKindEnumKind:
-		// 	return t.AsEnum.Name
-		// case dagger.TypeDefKindInputKind:
-		// 	return t.AsInput.Name
-		// case dagger.TypeDefKindObjectKind:
-		// 	return t.AsObject.Name
-		// case dagger.TypeDefKindInterfaceKind:
-		// 	return t.AsInterface.Name
-		case dagger.TypeDefKindListKind:
-			// props["type"] = "array"
-			// props["items"] = map[string]string{
-			// 	"type": arg.TypeDef.AsList.ElementTypeDef.String(),
-			// }
-		default:
-			panic(fmt.Sprintf("unsupported type: %s", arg.TypeDef.Kind))
-		}
+func mcpProperties(schema *jsonschema.Schema) map[string]any {
+	properties := map[string]any{}
+	for name, prop := range schema.Properties {
+		properties[name] = prop.Map()
 	}
-
-	tool := mcp.NewTool(t.Name(), opts...)
-
-	return tool
+	return properties
 }
 
-func (t *Tool) MCPHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	q := querybuilder.Query().Client(t.dag.GraphQLClient())
-
-	// Select module
-	q = q.Select(t.mod.Name)
-	// Bind top-level args
-	for k, v := range t.args {
-		q = q.Arg(k, v)
-	}
-
-	// Select function
-	q = q.Select(t.fn.Name)
-	// Extract the location from the function call arguments
-	for arg, v := range request.Params.Arguments {
-		q = q.Arg(arg, v)
-	}
-
-	gql, err := q.Build(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build query: %w", err)
-	}
+// MCPHandler returns an MCP server handler calling t. Tools returning files,
+// directories or containers return them as rich content.
+//
+// Calls report progress to clients asking for it, and can be cancelled when
+// the server is set up with MCPHooks and MCPCancelHandler.
+func MCPHandler(t Tool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		arguments, err := json.Marshal(request.Params.Arguments)
+		if err != nil {
+			return nil, err
+		}
 
-	fmt.Fprintf(os.Stderr, "sending query: %s\n", gql)
+		ctx, done := startMCPCall(ctx, t.Name(), request)
+		defer done()
 
-	var response graphql.Response
+		if c, ok := t.(mcpCaller); ok {
+			return c.callMCP(ctx, string(arguments))
+		}
 
-	err = t.dag.GraphQLClient().MakeRequest(ctx,
-		&graphql.Request{
-			Query: gql,
-		},
-		&response,
-	)
-	if err != nil {
-		return nil, err
-	}
+		result, err := t.Call(ctx, string(arguments))
+		if err != nil {
+			return nil, err
+		}
 
-	data, err := json.Marshal(response.Data)
-	if err != nil {
-		return nil, err
+		return mcp.NewToolResultText(result), nil
 	}
-
-	return mcp.NewToolResultText(string(data)), err
 }
 
-func (t *Tool) Call(ctx context.Context, arguments string) (string, error) {
-	q := querybuilder.Query().Client(t.dag.GraphQLClient())
-
-	// Select module
-	q = q.Select(t.mod.Name)
-	// Bind top-level args
-	for k, v := range t.args {
-		q = q.Arg(k, v)
-	}
+// mcpCaller is implemented by tools rendering their results as rich MCP
+// content, such as files, or structured content.
+type mcpCaller interface {
+	callMCP(ctx context.Context, arguments string) (*mcp.CallToolResult, error)
+}
 
-	// Select function
-	q = q.Select(t.fn.Name)
-	// Extract the location from the function call arguments
+// decodeArguments decodes the arguments of a call and validates them against
+// the schema of the tool.
+func decodeArguments(tool string, schema *jsonschema.Schema, arguments string) (map[string]any, error) {
 	var args map[string]any
 	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-		panic(err)
-	}
-	for arg, v := range args {
-		q = q.Arg(arg, v)
+		return nil, &ArgumentsError{Tool: tool, Problems: []string{fmt.Sprintf("invalid JSON: %v", err)}}
 	}
-
-	gql, err := q.Build(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to build query: %w", err)
+	if err := schema.Validate(args); err != nil {
+		var verr *jsonschema.ValidationError
+		if errors.As(err, &verr) {
+			return nil, &ArgumentsError{Tool: tool, Problems: verr.Problems}
+		}
+		return nil, err
 	}
-
-	fmt.Fprintf(os.Stderr, "sending query: %s\n", gql)
-
-	var response graphql.Response
-
-	err = t.dag.GraphQLClient().MakeRequest(ctx,
-		&graphql.Request{
-			Query: gql,
-		},
-		&response,
-	)
-	if err != nil {
-		return "", err
+	for k, v := range args {
+		args[k] = intify(v)
 	}
+	return args, nil
+}
 
-	data, err := json.Marshal(response.Data)
-	if err != nil {
-		return "", err
+// intify converts whole numbers to ints: JSON numbers decode as floats, which
+// the query builder doesn't take.
+func intify(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = intify(item)
+		}
 	}
-
-	return string(data), err
+	return v
 }
 
-type Tools []*Tool
+type Tools []Tool
 
 func (t Tools) Functions() []openai.ChatCompletionToolParam {
 	params := make([]openai.ChatCompletionToolParam, 0, len(t))
 	for _, tool := range t {
-		params = append(params, tool.Params())
+		params = append(params, Params(tool))
 	}
 	return params
 }
 
-func (t Tools) Get(name string) *Tool {
+func (t Tools) Get(name string) Tool {
 	for _, tool := range t {
 		if tool.Name() == name {
 			return tool
@@ -324,14 +169,53 @@ func (t Tools) Get(name string) *Tool {
 func (t Tools) Dispatch(ctx context.Context, name, arguments string) (string, error) {
 	tool := t.Get(name)
 	if tool == nil {
-		return "", fmt.Errorf("tool not found: %s", name)
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
 	}
 	return tool.Call(ctx, arguments)
 }
 
+// Result extracts the value returned by a call of t from its output: module
+// tools nest it under the names of the module and function, other tools
+// return it as is. Outputs that aren't JSON are returned as a JSON string.
+func Result(t Tool, output string) json.RawMessage {
+	if mt, ok := t.(*ModuleTool); ok {
+		var data map[string]map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(output), &data); err == nil {
+			if v, ok := data[mt.mod.Name][mt.fn.Name]; ok {
+				return v
+			}
+		}
+	}
+	if json.Valid([]byte(output)) {
+		return json.RawMessage(output)
+	}
+	data, _ := json.Marshal(output)
+	return data
+}
+
+// transporter is implemented by tools executing calls through a Transport.
+type transporter interface {
+	Transport() Transport
+	SetTransport(Transport)
+}
+
+// WrapTransport replaces the transport of every tool with the result of wrap.
+func (t Tools) WrapTransport(wrap func(Transport) Transport) {
+	for _, tool := range t {
+		if tt, ok := tool.(transporter); ok {
+			tt.SetTransport(wrap(tt.Transport()))
+		}
+	}
+}
+
+// InitFromEnv configures module tools from the environment.
 func (t Tools) InitFromEnv() error {
 	for _, tool := range t {
-		if err := tool.InitFromEnv(); err != nil {
+		mt, ok := tool.(*ModuleTool)
+		if !ok {
+			continue
+		}
+		if err := mt.InitFromEnv(); err != nil {
 			return err
 		}
 	}
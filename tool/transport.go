@@ -0,0 +1,69 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/Khan/genqlient/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Invocation describes a single tool call as sent to the engine.
+type Invocation struct {
+	// Tool is the name of the tool being called.
+	Tool string
+
+	// Arguments are the JSON encoded arguments, as provided by the caller.
+	Arguments string
+
+	// Query is the GraphQL query built for the call.
+	Query string
+
+	// Command is the equivalent `dagger call` command line.
+	Command string
+}
+
+// Transport executes tool invocations.
+//
+// The default transport sends the query to the dagger engine. Wrapping it
+// allows observing, recording or replaying tool calls.
+type Transport interface {
+	Execute(ctx context.Context, inv *Invocation) (json.RawMessage, error)
+}
+
+// TransportFunc is an adapter to use ordinary functions as a Transport.
+type TransportFunc func(ctx context.Context, inv *Invocation) (json.RawMessage, error)
+
+func (f TransportFunc) Execute(ctx context.Context, inv *Invocation) (json.RawMessage, error) {
+	return f(ctx, inv)
+}
+
+type graphqlTransport struct {
+	client graphql.Client
+}
+
+func (t *graphqlTransport) Execute(ctx context.Context, inv *Invocation) (json.RawMessage, error) {
+	var response graphql.Response
+
+	err := t.client.MakeRequest(ctx,
+		&graphql.Request{
+			Query: inv.Query,
+		},
+		&response,
+	)
+	var errs gqlerror.List
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			if execErr := parseExecError(inv.Tool, e); execErr != nil {
+				return nil, execErr
+			}
+		}
+		return nil, &QueryError{Tool: inv.Tool, Errors: errs}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(response.Data)
+}